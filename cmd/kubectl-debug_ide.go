@@ -17,7 +17,10 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"devfile.io/kubectl-cde/pkg"
 
@@ -30,8 +33,12 @@ func main() {
 	flags := pflag.NewFlagSet("kubectl-debug_ide", pflag.ExitOnError)
 	pflag.CommandLine = flags
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	root := pkg.NewCmdDebugIDE(genericiooptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr})
-	if err := root.Execute(); err != nil {
+	if err := root.ExecuteContext(ctx); err != nil {
+		stop()
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,255 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	backendAuto         = "auto"
+	backendDevWorkspace = "devworkspace"
+	backendNative       = "native"
+)
+
+var backends = []string{backendAuto, backendDevWorkspace, backendNative}
+
+// sessionBackend creates the copy of the target Pod where the IDE runs
+type sessionBackend interface {
+	// objects returns the objects of the session, printed with --dry-run=client
+	objects() ([]runtime.Object, error)
+	// start creates the objects of the session and waits for its Pod to be
+	// ready. It returns the Pod and the URL of the IDE, empty if the IDE
+	// isn't exposed by the cluster.
+	start(ctx context.Context) (*corev1.Pod, string, error)
+	// ideContainer is the name of the container of the Pod running the IDE
+	ideContainer() string
+}
+
+func validateBackend(backend string) error {
+	if contains(backends, backend) {
+		return nil
+	}
+	return fmt.Errorf("--backend must be one of %s", strings.Join(backends, ", "))
+}
+
+// devWorkspaceInstalled discovers if the DevWorkspace CRDs are installed
+func devWorkspaceInstalled(mapper meta.RESTMapper) (bool, error) {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return false, err
+	}
+	_, err = mapper.RESTMapping(schema.GroupKind{Group: gv.Group, Kind: kind}, gv.Version)
+	if meta.IsNoMatchError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("RESTMapping error: %v", err)
+	}
+	return true, nil
+}
+
+// completeBackend resolves --backend auto: the native backend is
+// used when the DevWorkspace Operator isn't installed
+func (o *DebugIDEOptions) completeBackend() error {
+	if o.backend != backendAuto {
+		return nil
+	}
+	installed, err := devWorkspaceInstalled(o.clients.mapper)
+	if err != nil {
+		return err
+	}
+	if installed {
+		o.backend = backendDevWorkspace
+		return nil
+	}
+	o.backend = backendNative
+	fmt.Fprintf(o.ErrOut, "🧩 the DevWorkspace Operator isn't installed, the copy is created as a plain Pod\n")
+	return nil
+}
+
+func (o *DebugIDEOptions) sessionBackend() sessionBackend {
+	if o.backend == backendNative {
+		return &nativeBackend{o: o}
+	}
+	return &devWorkspaceBackend{o: o}
+}
+
+// devWorkspaceBackend creates the copy as a DevWorkspace, the
+// DevWorkspace Operator creates the Deployment and the Pod
+type devWorkspaceBackend struct {
+	o *DebugIDEOptions
+}
+
+func (b *devWorkspaceBackend) objects() ([]runtime.Object, error) {
+	o := b.o
+	dw, err := generate(*o)
+	if err != nil {
+		return nil, fmt.Errorf("error generating devworkspace: %v", err)
+	}
+	objs := []runtime.Object{}
+	if o.ssh {
+		objs = append(objs, sshSecret(dw.Name, o.sshKey))
+	}
+	for _, s := range o.gitSecrets() {
+		objs = append(objs, s)
+	}
+	objs = append(objs, o.configCopiesObjects()...)
+	if t := ideTemplate(o.ide, dw.Name); t != nil {
+		objs = append(objs, t)
+	}
+	return append(objs, &dw), nil
+}
+
+func (b *devWorkspaceBackend) ideContainer() string {
+	return b.o.ide.runtimeComponent
+}
+
+// start applies the DevWorkspace, in the namespace selected by the
+// kubeconfig flags, and waits for the IDE to be ready
+func (b *devWorkspaceBackend) start(ctx context.Context) (*corev1.Pod, string, error) {
+	o := b.o
+
+	// Generate the DevWorkspace
+	dw, err := generate(*o)
+	if err != nil {
+		return nil, "", fmt.Errorf("error generating devworkspace: %v", err)
+	}
+
+	namespace := o.clients.namespace
+	clientset := o.clients.clientset
+	dynClient := o.clients.dynClient
+
+	// Convert the DevWorkspace to an Unstructured
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&dw)
+	if err != nil {
+		return nil, "", fmt.Errorf("found error while converting resource to unstructured err - %v", err)
+	}
+	unstructuredResource := &unstructured.Unstructured{Object: obj}
+
+	// Automatically get the GroupVersionResource for the DevWorkspace
+	rm := o.clients.mapper
+	gvr, err := devWorkspaceResource(rm)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Check if the DevWorkspace already exist
+	dwClient := dynClient.Resource(gvr).Namespace(namespace)
+	result, err := dwClient.Get(
+		ctx,
+		dw.Name,
+		metav1.GetOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, "", fmt.Errorf("error checking for the existence of a DevWorkspace named %s: %v", dw.Name, err)
+	}
+	if result != nil && result.Object != nil {
+		if !o.replace {
+			return nil, "", fmt.Errorf("a DevWorkspace named %s already exists in namespace %s: choose another name with --copy-to or replace it with --replace", dw.Name, namespace)
+		}
+		if result.GetLabels()[managedByLabel] != managedByValue {
+			return nil, "", fmt.Errorf("the DevWorkspace %s wasn't created by kubectl debug-ide and cannot be replaced", dw.Name)
+		}
+		if err := deleteAndWait(ctx, dwClient, kind, dw.Name, true, o.timeout); err != nil {
+			return nil, "", err
+		}
+		fmt.Fprintf(o.Out, "🗑️ deleted devworkspace %s in namespace %s.\n", dw.Name, namespace)
+	}
+
+	// Create or update the DevWorkspaceTemplate of a local IDE devfile
+	if t := ideTemplate(o.ide, dw.Name); t != nil {
+		if err := applyIDETemplate(ctx, dynClient, rm, namespace, t); err != nil {
+			return nil, "", err
+		}
+		fmt.Fprintf(o.Out, "⌨️ applied devworkspacetemplate %s in namespace %s.\n", t.Name, namespace)
+	}
+
+	// Create or update the Secret with the ssh authorized key
+	if o.ssh {
+		if err := applySecret(ctx, o.clients, sshSecret(dw.Name, o.sshKey)); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// Check the existing git credentials Secret or create the session ones
+	if len(o.gitCredentialsSecret) > 0 {
+		if err := checkGitCredentialsSecret(ctx, o.clients, o.gitCredentialsSecret); err != nil {
+			return nil, "", err
+		}
+	}
+	o.warnGitSecrets()
+	for _, s := range o.gitSecrets() {
+		if err := applySecret(ctx, o.clients, s); err != nil {
+			return nil, "", err
+		}
+		fmt.Fprintf(o.Out, "🔐 applied secret %s in namespace %s.\n", s.Name, namespace)
+	}
+
+	// Create or update the copies of the ConfigMaps and Secrets of the target Pod
+	if err := o.applyConfigCopies(ctx); err != nil {
+		return nil, "", err
+	}
+
+	// Create the DevWorkspace
+	result, err = dwClient.Create(
+		ctx,
+		unstructuredResource,
+		metav1.CreateOptions{})
+	if err != nil {
+		return nil, "", fmt.Errorf("error creating custom resource: %v", err)
+	}
+	dwName := result.GetName()
+	fmt.Fprintf(o.Out, "⌨️ created devworkspace %s in namespace %s.\n", dwName, namespace)
+
+	waitCtx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+
+	progress := newProgressReporter(o.Out, result.GetCreationTimestamp().Time)
+
+	// Get the deployment name
+	dwUnstruct, err := waitForDevWorkspace(waitCtx, dwClient, dwName, progress, func(s session) bool {
+		return s.id != ""
+	})
+	if err != nil {
+		return nil, "", o.waitError(progress, "the devworkspace "+dwName+" has no id", err)
+	}
+	deploymentName := sessionFromUnstructured(*dwUnstruct).id
+
+	// Report the Events of the DevWorkspace objects while waiting
+	eventsCtx, stopEvents := context.WithCancel(waitCtx)
+	defer stopEvents()
+	go progress.watchEvents(eventsCtx, clientset, namespace, sessionEventFilter(dwName, deploymentName))
+
+	// Wait for deployment status condition available == true
+	progress.start("⏳ waiting for the deployment %s to be available...", deploymentName)
+	if _, err := waitForDeploymentAvailable(waitCtx, clientset, namespace, deploymentName, progress); err != nil {
+		return nil, "", o.waitError(progress, "the deployment "+deploymentName+" is not available", err)
+	}
+	progress.done("done\n")
+
+	// Wait for pod status condition ready == true
+	progress.start("🥑 waiting for the pod of the devworkspace %s to be ready...", dwName)
+	p, err := waitForPodReady(waitCtx, clientset, namespace, "controller.devfile.io/devworkspace_name="+dwName, progress)
+	if err != nil {
+		return nil, "", o.waitError(progress, "the pod of the devworkspace "+dwName+" is not ready", err)
+	}
+	progress.done("done (%s)\n", p.Name)
+
+	// Retrieve IDE URL, that isn't required when the IDE is accessed locally
+	dwUnstruct, err = waitForDevWorkspace(waitCtx, dwClient, dwName, progress, func(s session) bool {
+		return s.phase == devWorkspaceReady && (s.url != "" || o.local)
+	})
+	if err != nil {
+		return nil, "", o.waitError(progress, "the devworkspace "+dwName+" is not running", err)
+	}
+	progress.close()
+	return p, sessionFromUnstructured(*dwUnstruct).url, nil
+}
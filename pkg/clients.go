@@ -0,0 +1,73 @@
+package pkg
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// kubeClients are the Kubernetes clients built from the kubeconfig flags
+// (--kubeconfig, --context, --namespace etc.) and shared by all the phases
+// of a command. Tests replace them with fakes.
+type kubeClients struct {
+	namespace string
+	config    *rest.Config
+	clientset kubernetes.Interface
+	dynClient dynamic.Interface
+	// mapper is backed by the discovery client: it tells which
+	// resources (e.g. the DevWorkspaces) the cluster serves
+	mapper meta.RESTMapper
+	// builder returns a resource.Builder to look up the objects
+	// passed as arguments
+	builder func() *resource.Builder
+}
+
+// kubeClientsFunc builds the clients of a command
+type kubeClientsFunc func(getter genericclioptions.RESTClientGetter) (*kubeClients, error)
+
+func newKubeClients(getter genericclioptions.RESTClientGetter) (*kubeClients, error) {
+	config, err := getter.ToRESTConfig()
+	if clientcmd.IsEmptyConfig(err) {
+		return nil, errNoContext
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ClientConfig error: %v", err)
+	}
+
+	namespace, _, err := getter.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return nil, fmt.Errorf("namespace error: %v", err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("client creation failed: %v", err)
+	}
+
+	dynClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("dynamic client creation failed: %v", err)
+	}
+
+	mapper, err := getter.ToRESTMapper()
+	if err != nil {
+		return nil, fmt.Errorf("restmapper error: %v", err)
+	}
+
+	return &kubeClients{
+		namespace: namespace,
+		config:    config,
+		clientset: clientset,
+		dynClient: dynClient,
+		mapper:    mapper,
+		builder: func() *resource.Builder {
+			return resource.NewBuilder(getter)
+		},
+	}, nil
+}
@@ -20,32 +20,61 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strconv"
+	"path"
 	"strings"
+	"sync"
 	"time"
 
-	appv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/restmapper"
+	"k8s.io/apimachinery/pkg/util/validation"
 
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/spf13/cobra"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/cli-runtime/pkg/genericiooptions"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/tools/clientcmd/api"
 )
 
 var (
 	debugIDEExample = `
 	# Create a copy of the Pod <pod-name> with an extra sidecar container running an IDE and including the <repository-url> source code
-	%[1]s debug-ide <pod-name> --image <debug-image> --git-repository <repository-url>`
+	%[1]s debug-ide <pod-name> --image <debug-image> --git-repository <repository-url>
+
+	# Add an ephemeral container running an IDE to the Pod <pod-name>, sharing the process namespace of the container <container-name>
+	%[1]s debug-ide <pod-name> --target <container-name> --git-repository <repository-url>
+
+	# Create a copy of a running Pod of the Deployment <deployment-name> (or of its Pod template) with an extra sidecar container running an IDE
+	%[1]s debug-ide deployment/<deployment-name> --image <debug-image> --git-repository <repository-url>
+
+	# Create a copy of a Pod selected by label with an extra sidecar container running an IDE
+	%[1]s debug-ide -l app=<app-name> --image <debug-image> --git-repository <repository-url>
+
+	# Create a copy of the Pod <pod-name> with an IDE and forward the IDE and the application ports on localhost
+	%[1]s debug-ide <pod-name> --git-repository <repository-url> --local --browser
+
+	# Create a copy of the Pod <pod-name> with an IDE and configure ssh to connect to it with "ssh <pod-name>-dw"
+	%[1]s debug-ide <pod-name> --git-repository <repository-url> --ssh
+
+	# Create a copy of the Pod <pod-name> with an IDE and two git repositories, checking out the commit the image was built from
+	%[1]s debug-ide <pod-name> --git-repository <repository-url>#<commit> --git-repository <other-repository-url>
+
+	# Create a copy of the Pod <pod-name> with an IDE and clone a private repository using the matching entry of ~/.git-credentials
+	%[1]s debug-ide <pod-name> --git-repository <private-repository-url> --git-credentials
+
+	# Create a copy of the Pod <pod-name> with an IDE, upload the current directory and keep it in sync
+	%[1]s debug-ide <pod-name> --source-dir . --watch
+
+	# Create a copy of the Pod <pod-name> with an IDE, start the debugger of the application language and configure the IDE to attach to it
+	%[1]s debug-ide <pod-name> --git-repository <repository-url> --debugger auto
+
+	# Replace the debugging session outyet-debug with a new copy of the Pod <pod-name> running on the same node
+	%[1]s debug-ide <pod-name> --copy-to outyet-debug --replace --same-node
+
+	# Create a copy of the Pod <pod-name> as a plain Pod, without the DevWorkspace Operator
+	%[1]s debug-ide <pod-name> --git-repository <repository-url> --backend native
+
+	# Print the DevWorkspace that would be created, without creating it
+	%[1]s debug-ide <pod-name> --git-repository <repository-url> --dry-run=client -o yaml`
 
 	errNoContext = fmt.Errorf("no context is currently set, use %q to select a new one", "kubectl config use-context <context>")
 )
@@ -55,29 +84,78 @@ const (
 	defaultDebugImage   = "quay.io/devfile/universal-developer-image:ubi8-latest"
 )
 
-// DebugIDEOptions provides information required to update
-// the current context on a user's KUBECONFIG
+// DebugIDEOptions provides information required to create
+// a debugging session for a target Pod
 type DebugIDEOptions struct {
 	configFlags *genericclioptions.ConfigFlags
+	printFlags  *genericclioptions.PrintFlags
 
-	resultingContext     *api.Context
-	resultingContextName string
-
-	userSpecifiedCluster   string
-	userSpecifiedContext   string
-	userSpecifiedAuthInfo  string
-	userSpecifiedNamespace string
+	newClients kubeClientsFunc
+	clients    *kubeClients
 
 	targetPodName       string
+	targetPod           *corev1.Pod
+	targetPodRunning    bool
 	targetPodContainers []ContainerInfo
+	// targetPodInitContainers are the init containers that run to
+	// completion, the native sidecars are in targetPodContainers
+	targetPodInitContainers []ContainerInfo
+	targetPodVolumes        []corev1.Volume
+	targetContainer         string
+	selector                string
+	skipInitContainers      bool
+	disableProbes           bool
+	// configMapCopies and secretCopies are the copies of the ConfigMap
+	// and Secret keys of the target Pod env, see envCopies
+	configMapCopies []*corev1.ConfigMap
+	secretCopies    []*corev1.Secret
 
 	debugImage     string
+	backend        string
 	copyToPodName  string
+	replace        bool
+	sameNode       bool
 	shareProcesses bool
-	gitRepository  string
-	ideReference   string
-	rawConfig      api.Config
-	args           []string
+	ephemeral      bool
+	local          bool
+	openBrowser    bool
+	ssh            bool
+	sshPublicKey   string
+	sshKey         sshKey
+	dryRun         string
+	timeout        time.Duration
+
+	gitRepositoryArgs []string
+	gitRevision       string
+	gitRepositories   []gitRepository
+	detectGitSource   bool
+	ociLayout         string
+	imageLabels       func(ctx context.Context, image string, keychain authn.Keychain) (map[string]string, error)
+
+	gitCredentialsSecret string
+	gitToken             string
+	useGitCredentials    bool
+	gitSSHKeyFile        string
+	gitKnownHostsFile    string
+	gitCredentials       string
+	gitSSHPrivateKey     []byte
+	gitKnownHosts        string
+
+	sourceDir     string
+	sourceProject string
+	watch         bool
+
+	debugger       string
+	debugLanguage  string
+	debugContainer string
+	launchJSON     []byte
+
+	ideReference string
+	ideRegistry  string
+	ideComponent string
+	ideEnv       []string
+	ide          ideDefinition
+	args         []string
 
 	genericiooptions.IOStreams
 }
@@ -85,7 +163,17 @@ type DebugIDEOptions struct {
 // NewDebugIDEOptions provides an instance of DebugIDEOptions with default values
 func NewDebugIDEOptions(streams genericiooptions.IOStreams) *DebugIDEOptions {
 	return &DebugIDEOptions{
-		configFlags: genericclioptions.NewConfigFlags(true),
+		configFlags:    genericclioptions.NewConfigFlags(true),
+		newClients:     newKubeClients,
+		printFlags:     genericclioptions.NewPrintFlags("created"),
+		dryRun:         dryRunNone,
+		backend:        backendAuto,
+		shareProcesses: true,
+		timeout:        defaultWaitTimeout,
+		imageLabels:    registryImageLabels,
+
+		detectGitSource:   true,
+		gitKnownHostsFile: defaultGitKnownHostsPath,
 
 		IOStreams: streams,
 	}
@@ -93,24 +181,28 @@ func NewDebugIDEOptions(streams genericiooptions.IOStreams) *DebugIDEOptions {
 
 // NewCmdDebugIDE provides a cobra command wrapping DebugIDEOptions
 func NewCmdDebugIDE(streams genericiooptions.IOStreams) *cobra.Command {
-	o := NewDebugIDEOptions(streams)
+	return newCmdDebugIDE(NewDebugIDEOptions(streams))
+}
 
+func newCmdDebugIDE(o *DebugIDEOptions) *cobra.Command {
+	streams := o.IOStreams
 	cmd := &cobra.Command{
-		Use:          "debug-ide [pod] [flags]",
-		Short:        "Create a copy of a Pod and add a Cloud Development Environment to debug it.",
+		Use:          "debug-ide (POD | TYPE/NAME | TYPE -l SELECTOR) [flags]",
+		Short:        "Create a copy of a Pod, or attach to it, and add a Cloud Development Environment to debug it.",
 		Example:      fmt.Sprintf(debugIDEExample, "kubectl"),
 		SilenceUsage: true,
+		Args:         cobra.ArbitraryArgs,
 		Annotations: map[string]string{
 			cobra.CommandDisplayNameAnnotation: "kubectl debug-ide",
 		},
 		RunE: func(c *cobra.Command, args []string) error {
-			if err := o.Complete(c, args); err != nil {
+			if err := o.Complete(c.Context(), c, args); err != nil {
 				return err
 			}
 			if err := o.Validate(); err != nil {
 				return err
 			}
-			if err := o.Run(); err != nil {
+			if err := o.Run(c.Context()); err != nil {
 				return err
 			}
 
@@ -118,344 +210,429 @@ func NewCmdDebugIDE(streams genericiooptions.IOStreams) *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringVar(&o.ideReference, "ide", defaultIdeReference, "URI to the devfile with the IDE definition")
-	cmd.Flags().StringVar(&o.debugImage, "image", defaultDebugImage, "Image of the debug sidecar container")
-	cmd.Flags().StringVar(&o.gitRepository, "git-repository", o.gitRepository, "URL of the git repository with the source code of the application we want to debug")
-	cmd.Flags().StringVar(&o.copyToPodName, "copy-to", o.copyToPodName, "Name of the new Pod, copy of the target Pod")
-	cmd.Flags().BoolVar(&o.shareProcesses, "share-processes", o.shareProcesses, "If true, enable process namespace sharing in the copy")
-	o.configFlags.AddFlags(cmd.Flags())
+	cmd.Flags().StringVarP(&o.selector, "selector", "l", o.selector, "Selector (label query) to filter on, supports '=', '==', and '!='.(e.g. -l key1=value1,key2=value2)")
+	cmd.Flags().StringVar(&o.ideReference, "ide", defaultIdeReference, "IDE definition: URI or path of a devfile, or plugin registry id (e.g. che-incubator/che-idea/latest)")
+	cmd.Flags().StringVar(&o.ideRegistry, "ide-registry", defaultPluginRegistryURL, "URL of the plugin registry used to resolve IDE ids")
+	cmd.Flags().StringVar(&o.ideComponent, "ide-component", o.ideComponent, "Name of the IDE runtime component where --ide-env is applied (defaults to the only container component of a local devfile or to <ide-name>-runtime-description)")
+	cmd.Flags().StringArrayVar(&o.ideEnv, "ide-env", o.ideEnv, "Environment variable NAME=VALUE to set in the IDE runtime component (can be repeated)")
+	cmd.Flags().StringVar(&o.debugImage, "image", defaultDebugImage, "Image of the debug sidecar container (defaults to "+defaultEphemeralImage+" with an ephemeral container, that must bundle the IDE)")
+	cmd.Flags().StringArrayVar(&o.gitRepositoryArgs, "git-repository", o.gitRepositoryArgs, "URL of a git repository with the source code of the application we want to debug, optionally followed by #<branch|tag|commit> (can be repeated)")
+	cmd.Flags().StringVar(&o.gitRevision, "git-revision", o.gitRevision, "Branch, tag or commit to check out in the git repositories that don't specify one")
+	cmd.Flags().BoolVar(&o.detectGitSource, "detect-git-repository", o.detectGitSource, "If true and --git-repository is omitted, clone the repository and check out the revision of the target image OCI labels org.opencontainers.image.source and revision")
+	cmd.Flags().StringVar(&o.ociLayout, "oci-layout", o.ociLayout, "Path of a local OCI image layout where the target image labels are looked up instead of the registry")
+	cmd.Flags().StringVar(&o.gitCredentialsSecret, "git-credentials-secret", o.gitCredentialsSecret, "Name of an existing git credentials Secret, labelled "+gitCredentialLabel+"=true, used to clone the git repositories")
+	cmd.Flags().StringVar(&o.gitToken, "git-token", o.gitToken, "Token used to clone the https git repositories, stored in a git credentials Secret of the debugging session")
+	cmd.Flags().BoolVar(&o.useGitCredentials, "git-credentials", o.useGitCredentials, "If true, store the entries of "+defaultGitCredentialsPath+" matching the https git repositories in a git credentials Secret of the debugging session")
+	cmd.Flags().StringVar(&o.gitSSHKeyFile, "git-ssh-key", o.gitSSHKeyFile, "Path of the private ssh (deploy) key used to clone the git@ and ssh:// git repositories")
+	cmd.Flags().StringVar(&o.gitKnownHostsFile, "git-known-hosts", o.gitKnownHostsFile, "Path of the known_hosts file with the host keys of the git@ and ssh:// git repositories, checked when cloning them with --git-ssh-key")
+	cmd.Flags().StringVar(&o.sourceDir, "source-dir", o.sourceDir, "Local directory uploaded in "+projectsRoot+"/<directory-name> of the debugging container, instead of cloning a --git-repository (.gitignore is honoured)")
+	cmd.Flags().BoolVar(&o.watch, "watch", o.watch, "If true, keep the --source-dir and the debugging container in sync until interrupted")
+	cmd.Flags().StringVar(&o.debugger, "debugger", o.debugger, "Start the debugger of the application (go, python, java, node or auto to detect the language) and add the IDE launch configuration to attach to it")
+	cmd.Flags().StringVar(&o.backend, "backend", o.backend, "How the copy of the target Pod is created: devworkspace (a DevWorkspace), native (a plain Pod) or auto to use the DevWorkspace Operator if it's installed")
+	cmd.Flags().StringVar(&o.copyToPodName, "copy-to", o.copyToPodName, "Name of the copy of the target Pod, i.e. of its DevWorkspace (defaults to <pod-name>"+nameSuffix+")")
+	cmd.Flags().BoolVar(&o.replace, "replace", o.replace, "If true, delete the debugging session with the same name (see --copy-to), if any, and create a new one")
+	cmd.Flags().BoolVar(&o.skipInitContainers, "skip-init-containers", o.skipInitContainers, "If true, don't copy the init containers and the native sidecars of the target Pod")
+	cmd.Flags().BoolVar(&o.disableProbes, "disable-probes", o.disableProbes, "If true, don't copy the liveness, readiness and startup probes of the target containers, so that the copy isn't restarted while paused in a breakpoint")
+	cmd.Flags().BoolVar(&o.sameNode, "same-node", o.sameNode, "If true, schedule the copy on the node of the target Pod")
+	cmd.Flags().BoolVar(&o.shareProcesses, "share-processes", o.shareProcesses, "If true, enable process namespace sharing in the copy (required to attach a debugger to the target processes)")
+	cmd.Flags().BoolVar(&o.ephemeral, "ephemeral", o.ephemeral, "If true, add the IDE as an ephemeral container to the running Pod instead of creating a copy")
+	cmd.Flags().StringVar(&o.targetContainer, "target", o.targetContainer, "When using an ephemeral container, target processes in this container name (implies --ephemeral)")
+	cmd.Flags().BoolVar(&o.local, "local", o.local, "If true, forward the IDE and the application ports of the debugging Pod on localhost and keep running until interrupted")
+	cmd.Flags().BoolVar(&o.openBrowser, "browser", o.openBrowser, "If true, open the IDE in the default browser (requires --local)")
+	cmd.Flags().BoolVar(&o.ssh, "ssh", o.ssh, "If true, start sshd in the debugging container and add an entry to ~/.ssh/config to connect to it (the --image must include the OpenSSH server)")
+	cmd.Flags().StringVar(&o.sshPublicKey, "ssh-public-key", o.sshPublicKey, "Path of the ssh public key authorized to connect (requires --ssh, a key pair is generated if omitted)")
+	cmd.Flags().DurationVar(&o.timeout, "timeout", o.timeout, "The length of time to wait for the IDE to be ready")
+	cmd.Flags().StringVar(&o.dryRun, "dry-run", o.dryRun, `Must be "none" or "client". If client strategy, only print the objects that would be sent, without sending them.`)
+	cmd.Flags().Lookup("dry-run").NoOptDefVal = dryRunClient
+	o.printFlags.AddFlags(cmd)
+	o.configFlags.AddFlags(cmd.PersistentFlags())
+
+	cmd.AddCommand(NewCmdList(o.configFlags, streams))
+	cmd.AddCommand(NewCmdDelete(o.configFlags, streams))
+	cmd.AddCommand(NewCmdStop(o.configFlags, streams))
+	cmd.AddCommand(NewCmdResume(o.configFlags, streams))
+	cmd.AddCommand(NewCmdSSHProxy(o.configFlags, streams))
 
 	return cmd
 }
 
 // Complete sets all information required for creating a DevWorkspace
-func (o *DebugIDEOptions) Complete(cmd *cobra.Command, args []string) error {
+func (o *DebugIDEOptions) Complete(ctx context.Context, cmd *cobra.Command, args []string) error {
 	o.args = args
 
+	if o.dryRun == dryRunClient {
+		if err := o.printFlags.Complete("%s (dry run)"); err != nil {
+			return err
+		}
+	}
+
 	var err error
-	o.rawConfig, err = o.configFlags.ToRawKubeConfigLoader().RawConfig()
+	o.clients, err = o.newClients(o.configFlags)
 	if err != nil {
 		return err
 	}
+	namespace := o.clients.namespace
 
-	if len(args) == 0 {
-		return fmt.Errorf("cannot omit the target pod to debug")
-	}
-
-	if len(args) > 1 {
-		return fmt.Errorf("cannot specify more than one pod (args number is %d)", len(args))
+	pod, running, err := resolveTargetPod(ctx, o.clients.builder(), o.clients.clientset, namespace, args, o.selector)
+	if k8serrors.IsNotFound(err) {
+		return fmt.Errorf("%s in namespace %s not found", strings.Join(args, " "), namespace)
 	}
-
-	o.targetPodName = args[0]
-
-	if len(args) > 1 {
-		return fmt.Errorf("cannot specify more than one pod")
+	var statusError *k8serrors.StatusError
+	if errors.As(err, &statusError) {
+		return fmt.Errorf("error getting %s in namespace %s: %v",
+			strings.Join(args, " "), namespace, statusError.ErrStatus.Message)
 	}
-
-	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
-	configOverrides := &clientcmd.ConfigOverrides{}
-	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, configOverrides)
-
-	config, err := kubeConfig.ClientConfig()
 	if err != nil {
-		return fmt.Errorf("ClientConfig error: %v", err)
+		return err
 	}
+	o.targetPod = pod
+	o.targetPodName = pod.Name
+	o.targetPodRunning = running
 
-	namespace, _, _ := kubeConfig.Namespace()
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return fmt.Errorf("NewForConfig error: %v", err)
+	if len(o.targetContainer) > 0 {
+		o.ephemeral = true
 	}
-
-	pod, err := clientset.CoreV1().Pods(namespace).Get(context.TODO(), o.targetPodName, metav1.GetOptions{})
-	if k8serrors.IsNotFound(err) {
-		return fmt.Errorf("pod %s in namespace %s not found", pod, namespace)
+	// Ephemeral containers cannot mount a volume with the IDE: the
+	// image must bundle it and replaces the default tooling image
+	if o.ephemeral && !cmd.Flags().Changed("image") {
+		o.debugImage = defaultEphemeralImage
+		fmt.Fprintf(o.ErrOut, "🧩 the ephemeral container runs %s, that bundles the IDE, instead of %s\n",
+			defaultEphemeralImage, defaultDebugImage)
 	}
-	var statusError *k8serrors.StatusError
-	if errors.As(err, &statusError) {
-		return fmt.Errorf("error getting pod %s in namespace %s: %v",
-			pod, namespace, statusError.ErrStatus.Message)
-	}
-
-	podContainersNum := len(pod.Spec.Containers)
-	o.targetPodContainers = make([]ContainerInfo, podContainersNum)
-	for i := 0; i < podContainersNum; i++ {
-		o.targetPodContainers[i].name = pod.Spec.Containers[i].Name
-		o.targetPodContainers[i].image = pod.Spec.Containers[i].Image
-		o.targetPodContainers[i].memoryLimit = pod.Spec.Containers[i].Resources.Limits.Memory().String()
-		o.targetPodContainers[i].cpuLimit = pod.Spec.Containers[i].Resources.Limits.Cpu().String()
-		containerPorts := len(pod.Spec.Containers[i].Ports)
-		o.targetPodContainers[i].endpoints = make([]ContainerEndpoint, podContainersNum)
-		for j := 0; j < containerPorts; j++ {
-			portName := pod.Spec.Containers[i].Ports[j].Name
-			portNumber := int(pod.Spec.Containers[i].Ports[j].ContainerPort)
-			if portName == "" {
-				portName = "port" + strconv.Itoa(portNumber)
-			}
-			o.targetPodContainers[i].endpoints[i].name = portName
-			o.targetPodContainers[i].endpoints[i].targetPort = portNumber
+
+	if !o.ephemeral {
+		if err := o.completeBackend(); err != nil {
+			return err
+		}
+		o.ide, err = resolveIDE(o.ideReference, o.ideRegistry, o.ideComponent, o.ideEnv)
+		if err != nil {
+			return err
 		}
 	}
 
-	o.userSpecifiedNamespace, err = cmd.Flags().GetString("namespace")
+	o.gitRepositories, err = parseGitRepositories(o.gitRepositoryArgs, o.gitRevision)
 	if err != nil {
 		return err
 	}
-
-	o.userSpecifiedContext, err = cmd.Flags().GetString("context")
-	if err != nil {
-		return err
+	if len(o.sourceDir) > 0 {
+		o.sourceDir, o.sourceProject, err = sourceProject(o.sourceDir)
+		if err != nil {
+			return err
+		}
 	}
-
-	o.userSpecifiedCluster, err = cmd.Flags().GetString("cluster")
-	if err != nil {
-		return err
+	if len(o.gitRepositories) == 0 && len(o.sourceDir) == 0 && o.detectGitSource {
+		repo, found, err := o.detectGitRepository(ctx)
+		switch {
+		case err != nil:
+			fmt.Fprintf(o.ErrOut, "warning: cannot detect the git repository: %v\n", err)
+		case found:
+			o.gitRepositories, err = parseGitRepositories([]string{repo.String()}, o.gitRevision)
+			if err != nil {
+				fmt.Fprintf(o.ErrOut, "warning: ignoring the git repository of the image labels: %v\n", err)
+				break
+			}
+			fmt.Fprintf(o.ErrOut, "🔎 detected git repository %s from the image labels\n", o.gitRepositories[0])
+		}
 	}
 
-	o.userSpecifiedAuthInfo, err = cmd.Flags().GetString("user")
+	switch {
+	case len(o.gitToken) > 0:
+		o.gitCredentials, err = tokenCredentials(o.gitRepositories, o.gitToken)
+	case o.useGitCredentials:
+		o.gitCredentials, err = readGitCredentials(defaultGitCredentialsPath, o.gitRepositories)
+	}
 	if err != nil {
 		return err
 	}
-
-	currentContext, exists := o.rawConfig.Contexts[o.rawConfig.CurrentContext]
-	if !exists {
-		return errNoContext
+	if len(o.gitSSHKeyFile) > 0 {
+		o.gitSSHPrivateKey, err = readGitSSHKey(o.gitSSHKeyFile)
+		if err != nil {
+			return err
+		}
+		o.gitKnownHosts, err = readGitKnownHosts(o.gitKnownHostsFile, o.gitRepositories)
+		if err != nil {
+			return err
+		}
 	}
 
-	o.resultingContext = api.NewContext()
-	o.resultingContext.Cluster = currentContext.Cluster
-	o.resultingContext.AuthInfo = currentContext.AuthInfo
-
-	// if a target context is explicitly provided by the user,
-	// use that as our reference for the final, resulting context
-	if len(o.userSpecifiedContext) > 0 {
-		o.resultingContextName = o.userSpecifiedContext
-		if userCtx, exists := o.rawConfig.Contexts[o.userSpecifiedContext]; exists {
-			o.resultingContext = userCtx.DeepCopy()
+	if o.ssh {
+		if len(o.sshPublicKey) > 0 {
+			o.sshKey, err = readSSHPublicKey(o.sshPublicKey)
+		} else {
+			o.sshKey, err = generateSSHKey()
+		}
+		if err != nil {
+			return err
 		}
 	}
 
-	// override context info with user provided values
-	o.resultingContext.Namespace = o.userSpecifiedNamespace
-
-	if len(o.userSpecifiedCluster) > 0 {
-		o.resultingContext.Cluster = o.userSpecifiedCluster
-	}
-	if len(o.userSpecifiedAuthInfo) > 0 {
-		o.resultingContext.AuthInfo = o.userSpecifiedAuthInfo
+	o.completeTargetContainers(pod)
+	if o.backend == backendDevWorkspace && !o.ephemeral {
+		o.warnDownwardAPIEnv(o.copiedContainers())
+		o.configMapCopies, o.secretCopies, err = envCopies(ctx, o.clients, o.devWorkspaceName(), o.copiedContainers())
+		if err != nil {
+			return err
+		}
 	}
 
-	// generate a unique context name based on its new values if
-	// user did not explicitly request a context by name
-	if len(o.userSpecifiedContext) == 0 {
-		o.resultingContextName = generateContextName(o.resultingContext)
+	if len(o.debugger) > 0 && !o.ephemeral {
+		if err := o.completeDebugger(); err != nil {
+			return err
+		}
+		if err := o.checkDebuggerPodSecurity(ctx); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func generateContextName(fromContext *api.Context) string {
-	name := fromContext.Namespace
-	if len(fromContext.Cluster) > 0 {
-		name = fmt.Sprintf("%s/%s", name, fromContext.Cluster)
-	}
-	if len(fromContext.AuthInfo) > 0 {
-		cleanAuthInfo := strings.Split(fromContext.AuthInfo, "/")[0]
-		name = fmt.Sprintf("%s/%s", name, cleanAuthInfo)
-	}
-
-	return name
-}
-
 // Validate ensures that all required arguments and flag values are provided
 func (o *DebugIDEOptions) Validate() error {
-	if len(o.rawConfig.CurrentContext) == 0 {
-		return errNoContext
+	if err := validateDryRun(o.dryRun); err != nil {
+		return err
 	}
-	return nil
-}
-
-// Run lists all available namespaces on a user's KUBECONFIG or updates the
-// current context based on a provided namespace.
-// Apply a DevWorkspace object
-func (o *DebugIDEOptions) Run() error {
-	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
-	configOverrides := &clientcmd.ConfigOverrides{}
-	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, configOverrides)
-
-	config, err := kubeConfig.ClientConfig()
-	if err != nil {
-		return fmt.Errorf("ClientConfig error: %v", err)
+	if o.dryRun == dryRunNone && len(*o.printFlags.OutputFormat) > 0 {
+		return fmt.Errorf("--output can only be used with --dry-run=client")
 	}
-
-	dynClient, err := dynamic.NewForConfig(config)
-	if err != nil {
-		return fmt.Errorf("dynamic client creation failed: %v", err)
+	if o.openBrowser && !o.local {
+		return fmt.Errorf("--browser requires --local")
 	}
 
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return fmt.Errorf("client creation failed: %v", err)
+	if len(o.sshPublicKey) > 0 && !o.ssh {
+		return fmt.Errorf("--ssh-public-key requires --ssh")
 	}
 
-	// Generate the DevWorkspace
-	dw, err := generate(*o)
-	if err != nil {
-		return fmt.Errorf("error generating devworkspace: %v", err)
+	if len(o.gitToken) > 0 && o.useGitCredentials {
+		return fmt.Errorf("--git-token and --git-credentials cannot be used together")
 	}
-
-	// Convert the DevWorkspace to an Unstructured
-	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&dw)
-	if err != nil {
-		return fmt.Errorf("found error while converting resource to unstructured err - %v", err)
+	if len(o.gitCredentialsSecret) > 0 && (len(o.gitToken) > 0 || o.useGitCredentials) {
+		return fmt.Errorf("--git-credentials-secret cannot be used with --git-token or --git-credentials")
+	}
+	if len(o.gitSSHKeyFile) > 0 && !hasSSHRemote(o.gitRepositories) {
+		return fmt.Errorf("--git-ssh-key requires a git@ or ssh:// --git-repository")
 	}
-	unstructuredResource := &unstructured.Unstructured{Object: obj}
 
-	// Automatically get the GroupVersionResource for the DevWorkspace
-	gvk := dw.GroupVersionKind()
-	gk := schema.GroupKind{Group: gvk.Group, Kind: gvk.Kind}
-	groupResources, err := restmapper.GetAPIGroupResources(clientset.Discovery())
-	if err != nil {
-		return fmt.Errorf("restmapper error: %v", err)
+	if len(o.sourceDir) > 0 && len(o.gitRepositories) > 0 {
+		return fmt.Errorf("--source-dir and --git-repository cannot be used together")
 	}
-	rm := restmapper.NewDiscoveryRESTMapper(groupResources)
-	mapping, err := rm.RESTMapping(gk, gvk.Version)
-	if err != nil {
-		return fmt.Errorf("RESTMapping error: %v", err)
+	if o.watch && len(o.sourceDir) == 0 {
+		return fmt.Errorf("--watch requires --source-dir")
 	}
 
-	// Check if the DevWorkspace already exist
-	namespace, _, _ := kubeConfig.Namespace()
-	result, err := dynClient.Resource(mapping.Resource).Namespace(namespace).Get(
-		context.TODO(),
-		dw.Name,
-		metav1.GetOptions{})
-	if err != nil && !k8serrors.IsNotFound(err) {
-		return fmt.Errorf("error checking for the existence of a DevWorkspace named %s: %v", dw.Name, err)
+	if err := validateDebugger(o.debugger); err != nil {
+		return err
 	}
-	if result != nil && result.Object != nil {
-		return fmt.Errorf("a DevWorkspace named %s already exist. Delete it to create a new one", dw.Name)
+	if err := validateBackend(o.backend); err != nil {
+		return err
 	}
-
-	// Create the DevWorkspace
-	result, err = dynClient.Resource(mapping.Resource).Namespace(namespace).Create(
-		context.TODO(),
-		unstructuredResource,
-		metav1.CreateOptions{})
-	if err != nil {
-		return fmt.Errorf("error creating custom resource: %v", err)
+	if o.backend == backendNative {
+		if o.ideReference != defaultIdeReference {
+			return fmt.Errorf("--ide cannot be used with the native backend, the IDE is che-code")
+		}
+		if o.ssh {
+			return fmt.Errorf("--ssh requires the devworkspace backend")
+		}
+		if len(o.gitCredentialsSecret) > 0 || len(o.gitCredentials) > 0 || len(o.gitSSHKeyFile) > 0 {
+			return fmt.Errorf("git credentials require the devworkspace backend")
+		}
+	}
+	if o.backend == backendDevWorkspace && !o.ephemeral {
+		if err := validateDevWorkspaceNames(*o); err != nil {
+			return err
+		}
+	}
+	if debuggerNeedsPtrace(o.debugLanguage) && !o.shareProcesses {
+		return fmt.Errorf("--debugger %s requires --share-processes", o.debugLanguage)
+	}
+	if o.sameNode && (o.targetPod == nil || len(o.targetPod.Spec.NodeName) == 0) {
+		return fmt.Errorf("--same-node requires a target Pod scheduled on a node")
+	}
+	if len(o.copyToPodName) > 0 {
+		if errs := validation.IsDNS1123Label(o.copyToPodName); len(errs) > 0 {
+			return fmt.Errorf("invalid --copy-to %q: %s", o.copyToPodName, strings.Join(errs, ", "))
+		}
 	}
-	dwName := result.Object["metadata"].(map[string]interface{})["name"].(string)
-	fmt.Printf("⌨️ created devworkspace %s in namespace %s.\n", dwName, namespace)
 
-	// Get the deployment name
-	dwUnstruct, err := dynClient.Resource(mapping.Resource).Namespace(namespace).Get(
-		context.TODO(),
-		dwName,
-		metav1.GetOptions{})
-	if err != nil {
-		return fmt.Errorf("dynClient Get error: %v", err)
-	}
-	deploymentName := dwUnstruct.Object["status"].(map[string]interface{})["devworkspaceId"].(string)
-
-	// Wait for deployment status condition available == true
-	fmt.Printf("⏳ waiting for the deployment %s to be available...", deploymentName)
-	var d *appv1.Deployment
-	available := false
-	timeout := 30
-	for i := 0; i < timeout; i++ {
-		d, err = clientset.AppsV1().Deployments(namespace).Get(context.TODO(), deploymentName, metav1.GetOptions{})
-		if err != nil && !k8serrors.IsNotFound(err) {
-			return fmt.Errorf("get Deployment error: %v", err)
-		}
-		for _, condition := range d.Status.Conditions {
-			if condition.Type == appv1.DeploymentAvailable {
-				if condition.Status == "True" {
-					available = true
-					break
-				} else if condition.Status == "False" {
-					fmt.Print(".")
-				}
-			}
+	if o.timeout <= 0 {
+		return fmt.Errorf("--timeout must be greater than zero")
+	}
+	if o.ephemeral {
+		if len(o.copyToPodName) > 0 {
+			return fmt.Errorf("--copy-to cannot be used with an ephemeral container")
+		}
+		if o.replace || o.sameNode {
+			return fmt.Errorf("--replace and --same-node cannot be used with an ephemeral container")
+		}
+		if o.backend != backendAuto {
+			return fmt.Errorf("--backend cannot be used with an ephemeral container")
+		}
+		if o.ideReference != defaultIdeReference || len(o.ideEnv) > 0 {
+			return fmt.Errorf("--ide and --ide-env cannot be used with an ephemeral container, the IDE is bundled in the --image")
+		}
+		if o.ssh {
+			return fmt.Errorf("--ssh cannot be used with an ephemeral container")
+		}
+		if len(o.gitCredentialsSecret) > 0 || len(o.gitCredentials) > 0 || len(o.gitSSHKeyFile) > 0 {
+			return fmt.Errorf("git credentials cannot be used with an ephemeral container")
+		}
+		if len(o.sourceDir) > 0 {
+			return fmt.Errorf("--source-dir cannot be used with an ephemeral container")
+		}
+		if len(o.debugger) > 0 {
+			return fmt.Errorf("--debugger cannot be used with an ephemeral container")
+		}
+		if len(o.gitRepositories) > 1 {
+			return fmt.Errorf("only one --git-repository can be cloned in an ephemeral container")
 		}
-		if available {
-			break
+		if !o.targetPodRunning {
+			return fmt.Errorf("no running pod found for %s, cannot add an ephemeral container", strings.Join(o.args, " "))
+		}
+		if len(o.targetContainer) > 0 && !hasContainer(o.targetPod, o.targetContainer) {
+			return fmt.Errorf("container %s not found in pod %s", o.targetContainer, o.targetPodName)
 		}
-		time.Sleep(1 * time.Second)
 	}
+	return nil
+}
 
-	if !available {
-		var conditions []appv1.DeploymentCondition
-		if d != nil {
-			conditions = d.Status.Conditions
+// completeTargetContainers sets the containers, init containers and
+// volumes of pod that are copied, unless --skip-init-containers. With
+// --disable-probes the probes are not copied, so that the copy isn't
+// restarted while the application is paused in a breakpoint.
+func (o *DebugIDEOptions) completeTargetContainers(pod *corev1.Pod) {
+	o.targetPodContainers = podContainers(pod, !o.skipInitContainers)
+	if o.disableProbes {
+		for i := range o.targetPodContainers {
+			c := &o.targetPodContainers[i]
+			c.livenessProbe, c.readinessProbe, c.startupProbe = nil, nil, nil
 		}
-		return fmt.Errorf("the deployment %s is not available after %d seconds (%v)", deploymentName, timeout, conditions)
 	}
+	o.targetPodInitContainers = nil
+	if !o.skipInitContainers {
+		o.targetPodInitContainers = podInitContainers(pod)
+	}
+	o.targetPodVolumes = podVolumes(pod, o.copiedContainers())
+}
 
-	fmt.Printf("done\n")
+// copiedContainers returns the init containers and the containers of the copy
+func (o *DebugIDEOptions) copiedContainers() []ContainerInfo {
+	containers := make([]ContainerInfo, 0, len(o.targetPodInitContainers)+len(o.targetPodContainers))
+	containers = append(containers, o.targetPodInitContainers...)
+	return append(containers, o.targetPodContainers...)
+}
 
-	// Get the Pod name
-	pods, err := clientset.CoreV1().Pods(namespace).List(context.TODO(),
-		metav1.ListOptions{
-			LabelSelector: "controller.devfile.io/devworkspace_name=" + dwName,
-		})
+// completeDebugger enables the debugger in the copy of the container to
+// debug and generates the launch configuration of the IDE
+func (o *DebugIDEOptions) completeDebugger() error {
+	if err := validateDebugger(o.debugger); err != nil {
+		return err
+	}
+	i, language, err := debugTarget(o.targetPodContainers, o.debugger)
 	if err != nil {
-		return fmt.Errorf("error listing devworkspace pods: %v", err)
+		return err
 	}
-	if len(pods.Items) == 0 {
-		return fmt.Errorf("no devworkspace pods found for DevWorkspace: %s", dwName)
+	ctr := &o.targetPodContainers[i]
+	if err := enableDebugger(ctr, language); err != nil {
+		return err
 	}
-	if len(pods.Items) > 1 {
-		return fmt.Errorf("more than one devworkspace pods found for DevWorkspace: %s", dwName)
+	o.launchJSON, err = launchJSON(language, *ctr)
+	if err != nil {
+		return err
 	}
-	podName := pods.Items[0].Name
+	o.debugLanguage, o.debugContainer = language, ctr.name
+	fmt.Fprintf(o.ErrOut, "🪲 %s debugger enabled in container %s\n", language, ctr.name)
+	return nil
+}
 
-	// Wait for pod status condition ready == true
-	fmt.Printf("🥑 waiting for the pod %s to be ready...", podName)
-	var p *corev1.Pod
-	ready := false
-	podReadinessTimeout := 30
-	for i := 0; i < podReadinessTimeout; i++ {
-		p, err = clientset.CoreV1().Pods(namespace).Get(context.TODO(), podName, metav1.GetOptions{})
-		if err != nil {
-			return fmt.Errorf("get Pod error: %v", err)
-		}
-		for _, condition := range p.Status.Conditions {
-			if condition.Type == corev1.PodReady {
-				if condition.Status == "True" {
-					ready = true
-					break
-				} else if condition.Status == "False" {
-					fmt.Print(".")
-				}
-			}
+func hasContainer(pod *corev1.Pod, name string) bool {
+	for _, c := range pod.Spec.Containers {
+		if c.Name == name {
+			return true
 		}
-		if ready {
-			break
+	}
+	return false
+}
+
+// Run creates the copy of the target Pod, in the namespace selected by the
+// kubeconfig flags, with the session backend and waits for the IDE to be ready
+func (o *DebugIDEOptions) Run(ctx context.Context) error {
+	if o.ephemeral {
+		return o.runEphemeral(ctx)
+	}
+
+	b := o.sessionBackend()
+	if o.dryRun == dryRunClient {
+		objs, err := b.objects()
+		if err != nil {
+			return err
 		}
-		time.Sleep(1 * time.Second)
+		return o.printObjects(objs...)
 	}
 
-	if !ready {
-		var conditions []corev1.PodCondition
-		if p != nil {
-			conditions = p.Status.Conditions
+	p, ideURL, err := b.start(ctx)
+	if err != nil {
+		return err
+	}
+	dwName := o.devWorkspaceName()
+
+	if o.ssh {
+		if err := o.configureSSH(dwName); err != nil {
+			return err
+		}
+		fmt.Fprintf(o.Out, "🔑 connect to the debugging container with \"ssh %s\"\n", sshHost(o.clients.namespace, dwName))
+	}
+
+	// Upload the local source code and keep it in sync while forwarding the ports
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	syncCtx, stopSync := context.WithCancel(ctx)
+	defer stopSync()
+	if len(o.sourceDir) > 0 {
+		remoteDir := path.Join(projectsRoot, o.sourceProject)
+		fmt.Fprintf(o.Out, "📂 uploading %s to %s...", o.sourceDir, remoteDir)
+		s := newSourceSync(o.sourceDir, remoteDir, podExec(o.clients, p.Name, defaultDevContainerName), o.Out, o.ErrOut)
+		n, err := s.upload(ctx)
+		if err != nil {
+			fmt.Fprintf(o.Out, "failed\n")
+			return err
+		}
+		fmt.Fprintf(o.Out, "done (%d files)\n", n)
+		if o.watch {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.watch(syncCtx)
+			}()
 		}
-		return fmt.Errorf("the pod %s is not ready after %d seconds (%v)", podName, podReadinessTimeout, conditions)
 	}
 
-	fmt.Printf("done\n")
+	if o.local {
+		endpoints := podEndpoints(p)
+		idePort, _ := ideEndpoint(endpoints, b.ideContainer())
+		return o.forwardPorts(ctx, p.Name, endpoints, b.ideContainer(), idePort, ideURL)
+	}
 
-	// Retrieve IDE URL
-	dwUnstruct, err = dynClient.Resource(mapping.Resource).Namespace(namespace).Get(
-		context.TODO(),
-		dwName,
-		metav1.GetOptions{})
-	if err != nil {
-		return fmt.Errorf("dynClient Get error: %v", err)
+	if len(ideURL) > 0 {
+		fmt.Fprintf(o.Out, "🐞 open the following link ⬇️ and start debugging\n\n")
+		fmt.Fprintf(o.Out, "%s\n", ideURL)
+	} else {
+		fmt.Fprintf(o.Out, "🐞 forward the IDE port ⬇️ and open http://localhost:%d to start debugging\n\n", nativeIDEPort)
+		fmt.Fprintf(o.Out, "kubectl port-forward -n %s pod/%s %d\n", o.clients.namespace, p.Name, nativeIDEPort)
+	}
+	if o.watch {
+		fmt.Fprintf(o.Out, "\n🔄 syncing %s, press Ctrl-C to stop\n", o.sourceDir)
+		<-ctx.Done()
 	}
-	dwMainURL := dwUnstruct.Object["status"].(map[string]interface{})["mainUrl"].(string)
-	fmt.Printf("🐞 open the following link ⬇️ and start debugging\n\n")
-	fmt.Printf("%s\n", dwMainURL)
 	return nil
 }
+
+// waitError prints the last known status of the session and adds
+// the timeout to the error returned when it expires
+func (o *DebugIDEOptions) waitError(progress *progressReporter, msg string, err error) error {
+	progress.fail()
+	progress.summary(o.ErrOut)
+	if errors.Is(err, errWaitTimeout) {
+		return fmt.Errorf("%s after %v", msg, o.timeout)
+	}
+	return fmt.Errorf("%s: %v", msg, err)
+}
@@ -0,0 +1,684 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	restfake "k8s.io/client-go/rest/fake"
+	"k8s.io/client-go/restmapper"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const (
+	testNamespace      = "dev"
+	testDevWorkspaceID = "workspace0a1b2c3d"
+	testIDEURL         = "https://devspaces.example.com/dev/outyet-dw/3100/"
+)
+
+var (
+	devWorkspaceGVR         = schema.GroupVersionResource{Group: "workspace.devfile.io", Version: "v1alpha2", Resource: "devworkspaces"}
+	devWorkspaceTemplateGVR = schema.GroupVersionResource{Group: "workspace.devfile.io", Version: "v1alpha2", Resource: "devworkspacetemplates"}
+)
+
+// operatorBehaviour is how the simulated DevWorkspace Operator handles a DevWorkspace
+type operatorBehaviour struct {
+	// phases are the phases that the DevWorkspace goes through, one for
+	// each time it's listed after its creation (i.e. every wait)
+	phases              []string
+	message             string
+	deploymentAvailable bool
+	podReady            bool
+}
+
+// fakeCluster holds the fake clients of a cluster, with or without the
+// DevWorkspace Operator, shared by the phases of a command
+type fakeCluster struct {
+	clientset *fake.Clientset
+	dynClient *dynamicfake.FakeDynamicClient
+	mapper    meta.RESTMapper
+
+	mu     sync.Mutex
+	lists  int
+	phases []string
+}
+
+func newFakeCluster(t *testing.T, devWorkspaceInstalled bool, objs ...runtime.Object) *fakeCluster {
+	t.Helper()
+	c := &fakeCluster{
+		clientset: fake.NewClientset(objs...),
+		dynClient: dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+			devWorkspaceGVR:         "DevWorkspaceList",
+			devWorkspaceTemplateGVR: "DevWorkspaceTemplateList",
+			podsResource:            "PodList",
+		}),
+	}
+	mappers := meta.MultiRESTMapper{testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme)}
+	if devWorkspaceInstalled {
+		dwMapper := meta.NewDefaultRESTMapper(nil)
+		dwMapper.Add(schema.GroupVersionKind{Group: "workspace.devfile.io", Version: "v1alpha2", Kind: kind}, meta.RESTScopeNamespace)
+		dwMapper.Add(schema.GroupVersionKind{Group: "workspace.devfile.io", Version: "v1alpha2", Kind: ideTemplateKind}, meta.RESTScopeNamespace)
+		mappers = append(mappers, dwMapper)
+	}
+	c.mapper = mappers
+	return c
+}
+
+func (c *fakeCluster) clients(genericclioptions.RESTClientGetter) (*kubeClients, error) {
+	return &kubeClients{
+		namespace: testNamespace,
+		clientset: c.clientset,
+		dynClient: c.dynClient,
+		mapper:    c.mapper,
+		builder:   c.builder,
+	}, nil
+}
+
+// builder returns a resource.Builder that reads the objects of the fake clientset
+func (c *fakeCluster) builder() *resource.Builder {
+	return resource.NewFakeBuilder(
+		func(gv schema.GroupVersion) (resource.RESTClient, error) {
+			return &restfake.RESTClient{
+				GroupVersion:         gv,
+				NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+				Client: restfake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+					return c.serve(gv, req)
+				}),
+			}, nil
+		},
+		func() (meta.RESTMapper, error) { return c.mapper, nil },
+		func() (restmapper.CategoryExpander, error) { return restmapper.SimpleCategoryExpander{}, nil },
+	)
+}
+
+// serve answers the GET /namespaces/<namespace>/<resource>[/<name>]
+// requests of the builder with the objects of the clientset tracker
+func (c *fakeCluster) serve(gv schema.GroupVersion, req *http.Request) (*http.Response, error) {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if req.Method != http.MethodGet || len(parts) < 3 || parts[0] != "namespaces" {
+		return nil, fmt.Errorf("unexpected request %s %s", req.Method, req.URL)
+	}
+	gvr := gv.WithResource(parts[2])
+	var obj runtime.Object
+	var err error
+	if len(parts) == 4 {
+		obj, err = c.clientset.Tracker().Get(gvr, parts[1], parts[3])
+	} else {
+		obj, err = c.list(gvr, parts[1], req.URL.Query().Get("labelSelector"))
+	}
+	if err != nil {
+		var status k8serrors.APIStatus
+		if !errors.As(err, &status) {
+			return nil, err
+		}
+		s := status.Status()
+		s.TypeMeta = metav1.TypeMeta{Kind: "Status", APIVersion: "v1"}
+		return jsonResponse(int(s.Code), &s)
+	}
+	body, err := runtime.Encode(scheme.Codecs.LegacyCodec(gv), obj)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{runtime.ContentTypeJSON}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}, nil
+}
+
+func (c *fakeCluster) list(gvr schema.GroupVersionResource, namespace, selector string) (runtime.Object, error) {
+	gvk, err := c.mapper.KindFor(gvr)
+	if err != nil {
+		return nil, err
+	}
+	list, err := c.clientset.Tracker().List(gvr, gvk, namespace)
+	if err != nil {
+		return nil, err
+	}
+	s, err := labels.Parse(selector)
+	if err != nil {
+		return nil, err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+	var selected []runtime.Object
+	for _, item := range items {
+		if m, err := meta.Accessor(item); err == nil && s.Matches(labels.Set(m.GetLabels())) {
+			selected = append(selected, item)
+		}
+	}
+	if err := meta.SetList(list, selected); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func jsonResponse(code int, obj interface{}) (*http.Response, error) {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: code,
+		Header:     http.Header{"Content-Type": []string{runtime.ContentTypeJSON}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}, nil
+}
+
+// simulateDevWorkspaceOperator emulates the DevWorkspace Operator: a created
+// DevWorkspace gets an id, the phase Starting, a Deployment and a Pod. Then
+// its phase changes every time it's listed again, according to b.
+func (c *fakeCluster) simulateDevWorkspaceOperator(b operatorBehaviour) {
+	c.dynClient.PrependReactor("create", "devworkspaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		u := action.(k8stesting.CreateAction).GetObject().(*unstructured.Unstructured)
+		_ = unstructured.SetNestedField(u.Object, testDevWorkspaceID, "status", "devworkspaceId")
+		_ = unstructured.SetNestedField(u.Object, "Starting", "status", "phase")
+
+		c.mu.Lock()
+		c.lists, c.phases = 0, b.phases
+		c.mu.Unlock()
+
+		d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: testDevWorkspaceID, Namespace: testNamespace}}
+		if b.deploymentAvailable {
+			d.Status.Conditions = []appsv1.DeploymentCondition{{Type: appsv1.DeploymentAvailable, Status: corev1.ConditionTrue}}
+		}
+		p := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      testDevWorkspaceID + "-7d9f8b6c5-x2x4z",
+				Namespace: testNamespace,
+				Labels:    map[string]string{"controller.devfile.io/devworkspace_name": u.GetName()},
+			},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: defaultDevContainerName}}},
+		}
+		if b.podReady {
+			p.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		}
+		for _, obj := range []runtime.Object{d, p} {
+			if err := c.clientset.Tracker().Add(obj); err != nil {
+				return true, nil, err
+			}
+		}
+		return false, nil, nil
+	})
+
+	c.dynClient.PrependReactor("list", "devworkspaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.lists++
+		if c.lists < 2 || len(c.phases) == 0 {
+			return false, nil, nil
+		}
+		phase := c.phases[0]
+		c.phases = c.phases[1:]
+
+		ns := action.GetNamespace()
+		list, err := c.dynClient.Tracker().List(devWorkspaceGVR, devWorkspaceGVR.GroupVersion().WithKind(kind), ns)
+		if err != nil {
+			return true, nil, err
+		}
+		for _, item := range list.(*unstructured.UnstructuredList).Items {
+			item := item
+			_ = unstructured.SetNestedField(item.Object, phase, "status", "phase")
+			_ = unstructured.SetNestedField(item.Object, b.message, "status", "message")
+			if phase == devWorkspaceReady {
+				_ = unstructured.SetNestedField(item.Object, testIDEURL, "status", "mainUrl")
+			}
+			if err := c.dynClient.Tracker().Update(devWorkspaceGVR, &item, ns); err != nil {
+				return true, nil, err
+			}
+		}
+		return false, nil, nil
+	})
+}
+
+// readyPods makes the Pods created by the native backend ready
+func (c *fakeCluster) readyPods() {
+	c.clientset.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		p := action.(k8stesting.CreateAction).GetObject().(*corev1.Pod)
+		p.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		return false, nil, nil
+	})
+}
+
+func targetPod() *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "outyet", Namespace: testNamespace},
+		Spec: corev1.PodSpec{
+			NodeName: "worker-1",
+			Containers: []corev1.Container{{
+				Name:  "outyet",
+				Image: "quay.io/l0rd/outyet:latest",
+				Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+// runDebugIDE runs the debug-ide command against the fake cluster
+func runDebugIDE(c *fakeCluster, args ...string) (string, string, error) {
+	streams, _, out, errOut := genericiooptions.NewTestIOStreams()
+	o := NewDebugIDEOptions(streams)
+	o.newClients = c.clients
+	cmd := newCmdDebugIDE(o)
+	cmd.SetArgs(append(args, "--detect-git-repository=false"))
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	err := cmd.ExecuteContext(context.Background())
+	return out.String(), errOut.String(), err
+}
+
+func Test_DebugIDE_devWorkspace(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		existing  []runtime.Object
+		behaviour operatorBehaviour
+		wantOut   []string
+		wantErr   string
+	}{
+		{
+			name: "running",
+			args: []string{"outyet", "--git-repository", "https://github.com/l0rd/outyet.git"},
+			behaviour: operatorBehaviour{
+				phases:              []string{devWorkspaceReady},
+				deploymentAvailable: true,
+				podReady:            true,
+			},
+			wantOut: []string{
+				"⌨️ created devworkspace outyet-dw in namespace dev.",
+				"waiting for the deployment " + testDevWorkspaceID + " to be available...done",
+				"waiting for the pod of the devworkspace outyet-dw to be ready...done (" + testDevWorkspaceID + "-7d9f8b6c5-x2x4z)",
+				testIDEURL,
+			},
+		},
+		{
+			name: "workload target and copy name",
+			args: []string{"pod/outyet", "--copy-to", "outyet-debug"},
+			behaviour: operatorBehaviour{
+				phases:              []string{devWorkspaceReady},
+				deploymentAvailable: true,
+				podReady:            true,
+			},
+			wantOut: []string{"⌨️ created devworkspace outyet-debug in namespace dev.", testIDEURL},
+		},
+		{
+			name:      "devworkspace failed",
+			args:      []string{"outyet", "--timeout", "2s"},
+			behaviour: operatorBehaviour{phases: []string{devWorkspaceFailed}, message: "Error pulling image", deploymentAvailable: true, podReady: true},
+			wantErr:   "the devworkspace outyet-dw is not running: the DevWorkspace outyet-dw failed: Error pulling image",
+		},
+		{
+			name:      "deployment not available",
+			args:      []string{"outyet", "--timeout", "200ms"},
+			behaviour: operatorBehaviour{phases: []string{devWorkspaceReady}},
+			wantErr:   "the deployment " + testDevWorkspaceID + " is not available after 200ms",
+		},
+		{
+			name:      "pod not ready",
+			args:      []string{"outyet", "--timeout", "200ms"},
+			behaviour: operatorBehaviour{phases: []string{devWorkspaceReady}, deploymentAvailable: true},
+			wantErr:   "the pod of the devworkspace outyet-dw is not ready after 200ms",
+		},
+		{
+			name:    "target not found",
+			args:    []string{"unknown"},
+			wantErr: "unknown in namespace dev not found",
+		},
+		{
+			name:    "invalid flags",
+			args:    []string{"outyet", "--browser"},
+			wantErr: "--browser requires --local",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newFakeCluster(t, true, append(tt.existing, targetPod())...)
+			c.simulateDevWorkspaceOperator(tt.behaviour)
+
+			out, _, err := runDebugIDE(c, tt.args...)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Run() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			for _, want := range tt.wantOut {
+				if !strings.Contains(out, want) {
+					t.Errorf("Run() output doesn't contain %q:\n%s", want, out)
+				}
+			}
+		})
+	}
+}
+
+const localIDEDevfile = `schemaVersion: 2.2.0
+components:
+  - name: my-ide-runtime
+    container:
+      image: quay.io/example/my-ide:latest
+`
+
+func Test_DebugIDE_replace(t *testing.T) {
+	existing := &unstructured.Unstructured{}
+	existing.SetAPIVersion(apiVersion)
+	existing.SetKind(kind)
+	existing.SetName("outyet-dw")
+	existing.SetNamespace(testNamespace)
+
+	for _, managed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("managed %t", managed), func(t *testing.T) {
+			c := newFakeCluster(t, true, targetPod())
+			obj := existing.DeepCopy()
+			if managed {
+				obj.SetLabels(sessionLabels())
+			}
+			if err := c.dynClient.Tracker().Add(obj); err != nil {
+				t.Fatal(err)
+			}
+			c.simulateDevWorkspaceOperator(operatorBehaviour{phases: []string{devWorkspaceReady}, deploymentAvailable: true, podReady: true})
+
+			ide := filepath.Join(t.TempDir(), "my-ide.yaml")
+			if err := os.WriteFile(ide, []byte(localIDEDevfile), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, _, err := runDebugIDE(c, "outyet", "--ide", ide)
+			if err == nil || !strings.Contains(err.Error(), "already exists") {
+				t.Fatalf("Run() without --replace error = %v, want already exists", err)
+			}
+			templates, err := c.dynClient.Resource(devWorkspaceTemplateGVR).Namespace(testNamespace).List(context.Background(), metav1.ListOptions{})
+			if err != nil || len(templates.Items) > 0 {
+				t.Errorf("Run() without --replace applied the DevWorkspaceTemplates %v, error %v", templates, err)
+			}
+
+			out, _, err := runDebugIDE(c, "outyet", "--replace")
+			if !managed {
+				if err == nil || !strings.Contains(err.Error(), "wasn't created by kubectl debug-ide") {
+					t.Fatalf("Run() --replace error = %v, want not managed", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() --replace error = %v", err)
+			}
+			if !strings.Contains(out, "🗑️ deleted devworkspace outyet-dw") || !strings.Contains(out, "⌨️ created devworkspace outyet-dw") {
+				t.Errorf("Run() --replace output:\n%s", out)
+			}
+		})
+	}
+}
+
+func Test_DebugIDE_native(t *testing.T) {
+	c := newFakeCluster(t, false, targetPod())
+	c.readyPods()
+
+	out, errOut, err := runDebugIDE(c, "outyet", "--git-repository", "https://github.com/l0rd/outyet.git", "--same-node")
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !strings.Contains(errOut, "the DevWorkspace Operator isn't installed") {
+		t.Errorf("Run() didn't report the backend: %q", errOut)
+	}
+	for _, want := range []string{
+		"⌨️ created pod outyet-dw in namespace dev.",
+		"waiting for the pod outyet-dw to be ready...done",
+		"kubectl port-forward -n dev pod/outyet-dw 3100",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Run() output doesn't contain %q:\n%s", want, out)
+		}
+	}
+
+	p, err := c.clientset.CoreV1().Pods(testNamespace).Get(context.Background(), "outyet-dw", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Spec.Affinity == nil || p.Labels[managedByLabel] != managedByValue {
+		t.Errorf("Run() created pod %+v", p.ObjectMeta)
+	}
+
+	_, _, err = runDebugIDE(c, "outyet", "--backend", "devworkspace")
+	if err == nil || !strings.Contains(err.Error(), "RESTMapping error") {
+		t.Errorf("Run() --backend devworkspace error = %v, want a RESTMapping error", err)
+	}
+	_, _, err = runDebugIDE(c, "outyet", "--ssh")
+	if err == nil || err.Error() != "--ssh requires the devworkspace backend" {
+		t.Errorf("Run() --ssh error = %v", err)
+	}
+}
+
+func Test_DebugIDE_dryRun(t *testing.T) {
+	c := newFakeCluster(t, true, targetPod())
+	out, _, err := runDebugIDE(c, "outyet", "--dry-run=client", "-o", "name")
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if want := "devworkspace.workspace.devfile.io/outyet-dw\n"; out != want {
+		t.Errorf("Run() output = %q, want %q", out, want)
+	}
+	if len(c.dynClient.Actions()) > 0 {
+		t.Errorf("Run() with --dry-run sent %v", c.dynClient.Actions())
+	}
+}
+
+func Test_DebugIDE_initContainerCopies(t *testing.T) {
+	pod := targetPod()
+	pod.Spec.InitContainers = []corev1.Container{{
+		Name:  "migrate",
+		Image: "quay.io/example/migrate:1.0.0",
+		Env: []corev1.EnvVar{{Name: "DB_PASSWORD", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "db"}, Key: "password",
+		}}}},
+		VolumeMounts: []corev1.VolumeMount{{Name: "migrations", MountPath: "/migrations"}},
+	}}
+	pod.Spec.Volumes = []corev1.Volume{{Name: "migrations", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "migrations"},
+	}}}}
+	db := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: testNamespace},
+		Data:       map[string][]byte{"password": []byte("s3cr3t")},
+	}
+	migrations := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "migrations", Namespace: testNamespace},
+		Data:       map[string]string{"001.sql": "CREATE TABLE t (id int);"},
+	}
+
+	c := newFakeCluster(t, true, pod, db, migrations)
+	c.simulateDevWorkspaceOperator(operatorBehaviour{phases: []string{devWorkspaceReady}, deploymentAvailable: true, podReady: true})
+	out, _, err := runDebugIDE(c, "outyet")
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	ctx := context.Background()
+	s, err := c.clientset.CoreV1().Secrets(testNamespace).Get(ctx, "outyet-dw-migrate-env", metav1.GetOptions{})
+	if err != nil || string(s.Data["DB_PASSWORD"]) != "s3cr3t" {
+		t.Errorf("Run() env Secret of the init container = %v, error %v", s, err)
+	}
+	if _, err := c.clientset.CoreV1().ConfigMaps(testNamespace).Get(ctx, "outyet-dw-migrations-volume", metav1.GetOptions{}); err == nil {
+		t.Errorf("Run() copied the volume of the init container")
+	}
+	if strings.Contains(out, "applied configmap") {
+		t.Errorf("Run() output:\n%s", out)
+	}
+
+	pod.Spec.InitContainers[0].VolumeMounts = append(pod.Spec.InitContainers[0].VolumeMounts,
+		corev1.VolumeMount{Name: "data", MountPath: "/data"})
+	pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{Name: "data", VolumeSource: corev1.VolumeSource{
+		PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "data"},
+	}})
+	c = newFakeCluster(t, true, pod, db, migrations)
+	out, _, err = runDebugIDE(c, "outyet", "--dry-run=client", "-o", "yaml")
+	if err != nil {
+		t.Fatalf("Run() with a PVC mounted by an init container error = %v", err)
+	}
+	for _, want := range []string{"claimName: data", "mountPath: /data", "mountPath: /migrations"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Run() with a PVC mounted by an init container output doesn't contain %q:\n%s", want, out)
+		}
+	}
+	if _, _, err = runDebugIDE(c, "outyet", "--skip-init-containers", "--dry-run=client"); err != nil {
+		t.Errorf("Run() --skip-init-containers error = %v", err)
+	}
+}
+
+// runEphemeralContainers makes the ephemeral containers added to a Pod running
+func (c *fakeCluster) runEphemeralContainers() {
+	c.clientset.PrependReactor("update", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "ephemeralcontainers" {
+			return false, nil, nil
+		}
+		p := action.(k8stesting.UpdateAction).GetObject().(*corev1.Pod)
+		for _, ec := range p.Spec.EphemeralContainers {
+			if ephemeralContainerStatus(p, ec.Name) == nil {
+				p.Status.EphemeralContainerStatuses = append(p.Status.EphemeralContainerStatuses, corev1.ContainerStatus{
+					Name:  ec.Name,
+					State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+				})
+			}
+		}
+		return false, nil, nil
+	})
+}
+
+func Test_DebugIDE_ephemeral(t *testing.T) {
+	c := newFakeCluster(t, false, targetPod())
+	c.runEphemeralContainers()
+	out, _, err := runDebugIDE(c, "outyet", "--target", "outyet", "--git-repository", "https://github.com/l0rd/outyet.git")
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	var updates []k8stesting.UpdateAction
+	for _, a := range c.clientset.Actions() {
+		if u, ok := a.(k8stesting.UpdateAction); ok && a.GetResource().Resource == "pods" && a.GetSubresource() == "ephemeralcontainers" {
+			updates = append(updates, u)
+		}
+	}
+	if len(updates) != 1 {
+		t.Fatalf("Run() sent %d ephemeral containers updates, want 1", len(updates))
+	}
+	p := updates[0].GetObject().(*corev1.Pod)
+	if len(p.Spec.EphemeralContainers) != 1 {
+		t.Fatalf("Run() ephemeral containers = %v, want 1", p.Spec.EphemeralContainers)
+	}
+	ec := p.Spec.EphemeralContainers[0]
+	if ec.TargetContainerName != "outyet" || ec.Image != defaultEphemeralImage {
+		t.Errorf("Run() ephemeral container = %s %s targeting %q", ec.Name, ec.Image, ec.TargetContainerName)
+	}
+
+	for _, w := range []string{
+		"⌨️ added ephemeral container " + ec.Name + " to pod outyet in namespace dev.",
+		"waiting for the container " + ec.Name + " to be running...",
+		"container " + ec.Name + ": running",
+		fmt.Sprintf("kubectl port-forward -n dev pod/outyet %d", ephemeralIDEPort),
+	} {
+		if !strings.Contains(out, w) {
+			t.Errorf("Run() output doesn't contain %q:\n%s", w, out)
+		}
+	}
+
+	// The Pod isn't running
+	pending := targetPod()
+	pending.Status.Phase = corev1.PodPending
+	c = newFakeCluster(t, false, pending)
+	_, _, err = runDebugIDE(c, "outyet", "--ephemeral")
+	if err == nil || !strings.Contains(err.Error(), "no running pod found") {
+		t.Errorf("Run() on a pending pod error = %v", err)
+	}
+}
+
+func Test_DebugIDE_ephemeralDryRun(t *testing.T) {
+	c := newFakeCluster(t, false, targetPod())
+	out, errOut, err := runDebugIDE(c, "outyet", "--ephemeral", "--dry-run=client", "-o", "yaml")
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	for _, w := range []string{"kind: Pod", "name: outyet", "ephemeralContainers:", "name: " + ephemeralContainerNamePrefix, "image: " + defaultEphemeralImage} {
+		if !strings.Contains(out, w) {
+			t.Errorf("Run() output doesn't contain %q:\n%s", w, out)
+		}
+	}
+	if !strings.Contains(errOut, "runs "+defaultEphemeralImage+", that bundles the IDE, instead of "+defaultDebugImage) {
+		t.Errorf("Run() didn't report the image replacement: %q", errOut)
+	}
+
+	image := "quay.io/example/che-code-tools:latest"
+	out, errOut, err = runDebugIDE(c, "outyet", "--ephemeral", "--image", image, "--dry-run=client", "-o", "yaml")
+	if err != nil {
+		t.Fatalf("Run() --image error = %v", err)
+	}
+	if !strings.Contains(out, "image: "+image) || strings.Contains(errOut, "bundles the IDE") {
+		t.Errorf("Run() --image didn't keep the image:\n%s\n%s", out, errOut)
+	}
+	for _, a := range c.clientset.Actions() {
+		if a.GetVerb() != "get" && a.GetVerb() != "list" {
+			t.Errorf("Run() with --dry-run sent %s %s", a.GetVerb(), a.GetResource().Resource)
+		}
+	}
+}
+
+func Test_DebugIDE_gitSSHKey(t *testing.T) {
+	dir := t.TempDir()
+	key := filepath.Join(dir, "deploy_key")
+	knownHosts := filepath.Join(dir, "known_hosts")
+	if err := os.WriteFile(key, []byte("private"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(knownHosts, []byte("gitlab.com ssh-ed25519 GITLAB\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"outyet", "--git-repository", "git@github.com:l0rd/private.git", "--git-ssh-key", key,
+		"--git-known-hosts", knownHosts, "--dry-run=client", "-o", "yaml"}
+
+	c := newFakeCluster(t, true, targetPod())
+	_, _, err := runDebugIDE(c, args...)
+	if err == nil || !strings.Contains(err.Error(), "no known_hosts entry of github.com") {
+		t.Fatalf("Run() without a known_hosts entry error = %v", err)
+	}
+
+	if err := os.WriteFile(knownHosts, []byte("github.com ssh-ed25519 GITHUB\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out, _, err := runDebugIDE(c, args...)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	for _, want := range []string{"id: " + gitSSHCloneCommandID, "secretName: outyet-dw-git-ssh-key", "name: outyet-dw-git-ssh-key"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Run() output doesn't contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, mountToDevWorkspaceLabel) {
+		t.Errorf("Run() mounts the ssh key in every DevWorkspace of the namespace:\n%s", out)
+	}
+}
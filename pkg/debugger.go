@@ -0,0 +1,232 @@
+package pkg
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	dwv1alpha2 "github.com/devfile/api/v2/pkg/apis/workspaces/v1alpha2"
+)
+
+const (
+	debuggerAuto   = "auto"
+	debuggerGo     = "go"
+	debuggerPython = "python"
+	debuggerJava   = "java"
+	debuggerNode   = "node"
+
+	// The debuggers listen on the loopback interface: the IDE runs in
+	// the same Pod and the ports don't need to be exposed
+	jdwpPort          = 5005
+	nodeInspectorPort = 9229
+	debugpyPort       = 5678
+	debuggerHost      = "127.0.0.1"
+	javaToolOptions   = "JAVA_TOOL_OPTIONS"
+	nodeOptions       = "NODE_OPTIONS"
+	launchCommandID   = "write-launch-json"
+	launchScript      = `mkdir -p "%[1]s/.vscode" && [ -f "%[1]s/.vscode/launch.json" ] || echo %[2]s | base64 -d > "%[1]s/.vscode/launch.json"`
+)
+
+var debuggers = []string{debuggerGo, debuggerPython, debuggerJava, debuggerNode}
+
+// debuggerCommands are the executables that tell the language of a container
+var debuggerCommands = map[string]string{
+	"java":    debuggerJava,
+	"node":    debuggerNode,
+	"npm":     debuggerNode,
+	"yarn":    debuggerNode,
+	"python":  debuggerPython,
+	"python3": debuggerPython,
+	"dlv":     debuggerGo,
+}
+
+// debuggerImageHints are the substrings of the image names that tell the
+// language of a container, when its command doesn't
+var debuggerImageHints = []struct {
+	language string
+	hints    []string
+}{
+	{debuggerJava, []string{"java", "jdk", "jre", "temurin", "quarkus", "spring", "tomcat", "wildfly"}},
+	{debuggerNode, []string{"node"}},
+	{debuggerPython, []string{"python"}},
+	{debuggerGo, []string{"golang"}},
+}
+
+func validateDebugger(debugger string) error {
+	if debugger == "" || debugger == debuggerAuto || contains(debuggers, debugger) {
+		return nil
+	}
+	return fmt.Errorf("--debugger must be one of %s or %s", strings.Join(debuggers, ", "), debuggerAuto)
+}
+
+// detectLanguage guesses the language of a container from its command,
+// arguments and image
+func detectLanguage(ctr ContainerInfo) (string, bool) {
+	for _, args := range [][]string{ctr.command, ctr.args} {
+		if len(args) == 0 {
+			continue
+		}
+		if l, ok := debuggerCommands[interpreter(args[0])]; ok {
+			return l, true
+		}
+	}
+	image := ctr.image
+	if i := strings.LastIndex(image, "/"); i != -1 {
+		image = image[i+1:]
+	}
+	for _, h := range debuggerImageHints {
+		for _, hint := range h.hints {
+			if strings.Contains(image, hint) {
+				return h.language, true
+			}
+		}
+	}
+	return "", false
+}
+
+// interpreter returns the name of an executable without its path
+// and version (e.g. /usr/bin/python3.12 -> python3)
+func interpreter(cmd string) string {
+	name := path.Base(cmd)
+	if i := strings.Index(name, "."); i != -1 {
+		name = name[:i]
+	}
+	return name
+}
+
+// debugTarget returns the index of the container to debug and its
+// language. With auto that's the first container whose language is
+// detected, otherwise the first one of the language or else the first one.
+func debugTarget(containers []ContainerInfo, debugger string) (int, string, error) {
+	if len(containers) == 0 {
+		return 0, "", fmt.Errorf("no container to debug")
+	}
+	for i, c := range containers {
+		if l, ok := detectLanguage(c); ok && (debugger == debuggerAuto || debugger == l) {
+			return i, l, nil
+		}
+	}
+	if debugger == debuggerAuto {
+		return 0, "", fmt.Errorf("cannot detect the language of the containers, use --debugger %s", strings.Join(debuggers, "|"))
+	}
+	return 0, debugger, nil
+}
+
+// enableDebugger adds the env variables or the arguments that start the
+// debugger of the language to the container. The Go processes are attached
+// by Delve from the CDE container, through the shared process namespace.
+func enableDebugger(ctr *ContainerInfo, language string) error {
+	switch language {
+	case debuggerJava:
+		return appendEnv(ctr, javaToolOptions,
+			fmt.Sprintf("-agentlib:jdwp=transport=dt_socket,server=y,suspend=n,address=%s:%d", debuggerHost, jdwpPort))
+	case debuggerNode:
+		return appendEnv(ctr, nodeOptions, fmt.Sprintf("--inspect=%s:%d", debuggerHost, nodeInspectorPort))
+	case debuggerPython:
+		debugpy := []string{"-m", "debugpy", "--listen", debuggerHost + ":" + strconv.Itoa(debugpyPort)}
+		switch {
+		case len(ctr.command) > 0 && debuggerCommands[interpreter(ctr.command[0])] == debuggerPython:
+			ctr.command = append(append([]string{ctr.command[0]}, debugpy...), ctr.command[1:]...)
+		case len(ctr.command) == 0 && len(ctr.args) > 0 && debuggerCommands[interpreter(ctr.args[0])] == debuggerPython:
+			ctr.args = append(append([]string{ctr.args[0]}, debugpy...), ctr.args[1:]...)
+		default:
+			return fmt.Errorf("cannot start debugpy in container %s: its command doesn't start with python", ctr.name)
+		}
+	}
+	return nil
+}
+
+// appendEnv appends value to the env variable name of the container
+func appendEnv(ctr *ContainerInfo, name, value string) error {
+	for i, e := range ctr.env {
+		if e.name != name {
+			continue
+		}
+		if e.valueFrom != nil {
+			return fmt.Errorf("cannot add %s to the env variable %s of container %s: its value comes from a reference", value, name, ctr.name)
+		}
+		ctr.env[i].value = strings.TrimSpace(e.value + " " + value)
+		return nil
+	}
+	ctr.env = append(ctr.env, ContainerEnv{name: name, value: value})
+	return nil
+}
+
+// launchConfiguration is the VS Code launch configuration that attaches
+// the IDE to the debugger of the container
+func launchConfiguration(language string, ctr ContainerInfo) map[string]interface{} {
+	c := map[string]interface{}{
+		"name":    "Attach to " + ctr.name,
+		"request": "attach",
+	}
+	switch language {
+	case debuggerGo:
+		c["type"] = "go"
+		c["mode"] = "local"
+		c["processId"] = "${command:pickProcess}"
+	case debuggerJava:
+		c["type"] = "java"
+		c["hostName"] = debuggerHost
+		c["port"] = jdwpPort
+	case debuggerNode:
+		c["type"] = "node"
+		c["address"] = debuggerHost
+		c["port"] = nodeInspectorPort
+		c["localRoot"] = "${workspaceFolder}"
+		if ctr.workingDir != "" {
+			c["remoteRoot"] = ctr.workingDir
+		}
+	case debuggerPython:
+		c["type"] = "debugpy"
+		c["connect"] = map[string]interface{}{"host": debuggerHost, "port": debugpyPort}
+		if ctr.workingDir != "" {
+			c["pathMappings"] = []map[string]string{{"localRoot": "${workspaceFolder}", "remoteRoot": ctr.workingDir}}
+		}
+	}
+	return c
+}
+
+// launchJSON returns the content of the .vscode/launch.json file
+func launchJSON(language string, ctr ContainerInfo) ([]byte, error) {
+	return json.MarshalIndent(map[string]interface{}{
+		"version":        "0.2.0",
+		"configurations": []interface{}{launchConfiguration(language, ctr)},
+	}, "", "  ")
+}
+
+// launchCommand is the postStart command that writes the launch.json in
+// the project folder dir, unless the project already has one
+func launchCommand(dir string, launch []byte) dwv1alpha2.Command {
+	return dwv1alpha2.Command{
+		Id: launchCommandID,
+		CommandUnion: dwv1alpha2.CommandUnion{
+			Exec: &dwv1alpha2.ExecCommand{
+				Component:   defaultDevContainerName,
+				CommandLine: launchCommandLine(dir, launch),
+			},
+		},
+	}
+}
+
+// launchCommandLine writes the launch.json in the project folder dir,
+// unless the project already has one
+func launchCommandLine(dir string, launch []byte) string {
+	return fmt.Sprintf(launchScript, dir, base64.StdEncoding.EncodeToString(launch))
+}
+
+// debugProjectDir is the folder of the first project, where the
+// launch.json is written, or the projects root if there is none
+func (o DebugIDEOptions) debugProjectDir() string {
+	if len(o.gitRepositories) > 0 {
+		if name, err := projectName(o.gitRepositories[0].remote); err == nil {
+			return path.Join(projectsRoot, name)
+		}
+	}
+	if len(o.sourceProject) > 0 {
+		return path.Join(projectsRoot, o.sourceProject)
+	}
+	return projectsRoot
+}
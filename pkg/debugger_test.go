@@ -0,0 +1,165 @@
+package pkg
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func Test_detectLanguage(t *testing.T) {
+	tests := []struct {
+		name   string
+		ctr    ContainerInfo
+		want   string
+		wantOk bool
+	}{
+		{name: "java command", ctr: ContainerInfo{image: "quay.io/l0rd/app", command: []string{"java", "-jar", "app.jar"}}, want: debuggerJava, wantOk: true},
+		{name: "versioned python", ctr: ContainerInfo{image: "quay.io/l0rd/app", command: []string{"/usr/bin/python3.12", "app.py"}}, want: debuggerPython, wantOk: true},
+		{name: "npm args", ctr: ContainerInfo{image: "quay.io/l0rd/app", args: []string{"npm", "start"}}, want: debuggerNode, wantOk: true},
+		{name: "node image", ctr: ContainerInfo{image: "docker.io/library/node:20"}, want: debuggerNode, wantOk: true},
+		{name: "quarkus image", ctr: ContainerInfo{image: "quay.io/quarkus/quarkus-micro-image:2.0"}, want: debuggerJava, wantOk: true},
+		{name: "registry name not used", ctr: ContainerInfo{image: "python.example.com/l0rd/outyet"}},
+		{name: "unknown", ctr: ContainerInfo{image: "quay.io/l0rd/outyet", command: []string{"/outyet"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := detectLanguage(tt.ctr)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("detectLanguage() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_debugTarget(t *testing.T) {
+	containers := []ContainerInfo{
+		{name: "proxy", image: "quay.io/l0rd/proxy"},
+		{name: "app", image: "quay.io/l0rd/app", command: []string{"node", "server.js"}},
+	}
+	tests := []struct {
+		name         string
+		debugger     string
+		wantIndex    int
+		wantLanguage string
+		wantErr      bool
+	}{
+		{name: "auto", debugger: debuggerAuto, wantIndex: 1, wantLanguage: debuggerNode},
+		{name: "detected language", debugger: debuggerNode, wantIndex: 1, wantLanguage: debuggerNode},
+		{name: "other language", debugger: debuggerGo, wantIndex: 0, wantLanguage: debuggerGo},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, l, err := debugTarget(containers, tt.debugger)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("debugTarget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if i != tt.wantIndex || l != tt.wantLanguage {
+				t.Errorf("debugTarget() = %d, %s, want %d, %s", i, l, tt.wantIndex, tt.wantLanguage)
+			}
+		})
+	}
+	if _, _, err := debugTarget(containers[:1], debuggerAuto); err == nil {
+		t.Errorf("debugTarget() expected an error when no language is detected")
+	}
+}
+
+func Test_enableDebugger(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		ctr      ContainerInfo
+		want     ContainerInfo
+		wantErr  bool
+	}{
+		{
+			name:     "java appends to JAVA_TOOL_OPTIONS",
+			language: debuggerJava,
+			ctr:      ContainerInfo{env: []ContainerEnv{{name: javaToolOptions, value: "-Xmx512m"}}},
+			want: ContainerInfo{env: []ContainerEnv{{name: javaToolOptions,
+				value: "-Xmx512m -agentlib:jdwp=transport=dt_socket,server=y,suspend=n,address=127.0.0.1:5005"}}},
+		},
+		{
+			name:     "node sets NODE_OPTIONS",
+			language: debuggerNode,
+			ctr:      ContainerInfo{},
+			want:     ContainerInfo{env: []ContainerEnv{{name: nodeOptions, value: "--inspect=127.0.0.1:9229"}}},
+		},
+		{
+			name:     "env from a reference",
+			language: debuggerNode,
+			ctr:      ContainerInfo{env: []ContainerEnv{{name: nodeOptions, valueFrom: &corev1.EnvVarSource{}}}},
+			wantErr:  true,
+		},
+		{
+			name:     "python command",
+			language: debuggerPython,
+			ctr:      ContainerInfo{command: []string{"python3", "app.py"}, args: []string{"--port", "8080"}},
+			want: ContainerInfo{command: []string{"python3", "-m", "debugpy", "--listen", "127.0.0.1:5678", "app.py"},
+				args: []string{"--port", "8080"}},
+		},
+		{
+			name:     "python args",
+			language: debuggerPython,
+			ctr:      ContainerInfo{args: []string{"python", "app.py"}},
+			want:     ContainerInfo{args: []string{"python", "-m", "debugpy", "--listen", "127.0.0.1:5678", "app.py"}},
+		},
+		{
+			name:     "python image entrypoint",
+			language: debuggerPython,
+			ctr:      ContainerInfo{args: []string{"--port", "8080"}},
+			wantErr:  true,
+		},
+		{
+			name:     "go is attached from the CDE",
+			language: debuggerGo,
+			ctr:      ContainerInfo{command: []string{"/outyet"}},
+			want:     ContainerInfo{command: []string{"/outyet"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctr := tt.ctr
+			err := enableDebugger(&ctr, tt.language)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("enableDebugger() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(ctr, tt.want) {
+				t.Errorf("enableDebugger() = %+v, want %+v", ctr, tt.want)
+			}
+		})
+	}
+}
+
+func Test_launchCommand(t *testing.T) {
+	launch, err := launchJSON(debuggerJava, ContainerInfo{name: "app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "configurations": [
+    {
+      "hostName": "127.0.0.1",
+      "name": "Attach to app",
+      "port": 5005,
+      "request": "attach",
+      "type": "java"
+    }
+  ],
+  "version": "0.2.0"
+}`
+	if string(launch) != want {
+		t.Fatalf("launchJSON() got:\n%s\nwant:\n%s", launch, want)
+	}
+
+	cmd := launchCommand("/projects/outyet", launch)
+	if cmd.Exec == nil || cmd.Exec.Component != defaultDevContainerName {
+		t.Fatalf("launchCommand() is not an exec command of the %s component", defaultDevContainerName)
+	}
+	if !strings.Contains(cmd.Exec.CommandLine, `[ -f "/projects/outyet/.vscode/launch.json" ] ||`) ||
+		!strings.Contains(cmd.Exec.CommandLine, base64.StdEncoding.EncodeToString(launch)) {
+		t.Errorf("launchCommand() command line = %s", cmd.Exec.CommandLine)
+	}
+}
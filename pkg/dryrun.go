@@ -0,0 +1,116 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	dryRunNone   = "none"
+	dryRunClient = "client"
+	outputYAML   = "yaml"
+	outputJSON   = "json"
+	redacted     = "REDACTED"
+)
+
+func validateDryRun(dryRun string) error {
+	switch dryRun {
+	case dryRunNone, dryRunClient:
+		return nil
+	default:
+		return fmt.Errorf(`invalid dry-run value (%v). Must be "none" or "client"`, dryRun)
+	}
+}
+
+// printObjects prints the objects that would be sent to the
+// cluster using the printer configured by the --output flag. The
+// yaml and json outputs are rendered deterministically, without
+// empty fields, so that they can be committed and diffed. The
+// values of the Secrets, such as the git credentials, are redacted.
+func (o *DebugIDEOptions) printObjects(objs ...runtime.Object) error {
+	objs = append([]runtime.Object{}, objs...)
+	for i, obj := range objs {
+		if s, ok := obj.(*corev1.Secret); ok {
+			objs[i] = redactSecret(s)
+			fmt.Fprintf(o.ErrOut, "🔐 the values of the secret %s are redacted, set them before applying it\n", s.Name)
+		}
+	}
+
+	switch *o.printFlags.OutputFormat {
+	case outputYAML:
+		return printRendered(o.Out, objs, renderYAML, "---\n")
+	case outputJSON:
+		if len(objs) == 1 {
+			return printRendered(o.Out, objs, renderJSON, "")
+		}
+		b, err := renderJSON(jsonList(objs))
+		if err != nil {
+			return err
+		}
+		_, err = o.Out.Write(b)
+		return err
+	}
+
+	printer, err := o.printFlags.ToPrinter()
+	if err != nil {
+		return err
+	}
+	for _, obj := range objs {
+		if err := printer.PrintObj(obj, o.Out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printRendered(out io.Writer, objs []runtime.Object, render func(interface{}) ([]byte, error), separator string) error {
+	for i, obj := range objs {
+		b, err := render(obj)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Fprint(out, separator)
+		}
+		if _, err := out.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonList wraps several objects in a List, as kubectl does,
+// so that the json output is a single valid document
+func jsonList(objs []runtime.Object) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items":      objs,
+		"metadata":   map[string]interface{}{"resourceVersion": ""},
+	}
+}
+
+// redactSecret returns a copy of s with the same keys but
+// without the values
+func redactSecret(s *corev1.Secret) *corev1.Secret {
+	r := s.DeepCopy()
+	r.Data = nil
+	r.StringData = make(map[string]string, len(s.Data)+len(s.StringData))
+	for k := range s.Data {
+		r.StringData[k] = redacted
+	}
+	for k := range s.StringData {
+		r.StringData[k] = redacted
+	}
+	return r
+}
+
+// withPodTypeMeta sets the Pod kind and apiVersion that are
+// not set on typed objects retrieved from the API server
+func withPodTypeMeta(pod *corev1.Pod) *corev1.Pod {
+	pod.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Pod"))
+	return pod
+}
@@ -2,10 +2,12 @@ package pkg
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 
 	dwv1alpha2 "github.com/devfile/api/v2/pkg/apis/workspaces/v1alpha2"
 	devfileattributes "github.com/devfile/api/v2/pkg/attributes"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -23,51 +25,21 @@ const (
 	defaultEndpointPath                  = "/"
 	defaultEndpointSecure                = false
 	defaultDevContainerName              = "cde"
-	defaultDevWorkspaceAttributes        = `{"controller.devfile.io/storage-type":"ephemeral","pod-overrides":{"spec":{"shareProcessNamespace":true}}}`
+	defaultDevWorkspaceAttributes        = `{"controller.devfile.io/storage-type":"ephemeral"}`
+	podOverridesAttribute                = "pod-overrides"
+	containerOverridesAttribute          = "container-overrides"
 	cheCodeContributionName              = "che-code"
-	cheCodeContributionComponentName     = "che-code-runtime-description"
 	cheCodeContributionContainerEnvName  = "CODE_HOST"
 	cheCodeContributionContainerEnvValue = "0.0.0.0"
-	cheCodeContributionURI               = "https://eclipse-che.github.io/che-plugin-registry/main/v3/plugins/che-incubator/che-code/latest/devfile.yaml"
 )
 
-var cheCodeContainer = dwv1alpha2.ContainerComponentPluginOverride{
-	BaseComponentPluginOverride: dwv1alpha2.BaseComponentPluginOverride{},
-	ContainerPluginOverride: dwv1alpha2.ContainerPluginOverride{
-		Env: []dwv1alpha2.EnvVarPluginOverride{
-			{Name: cheCodeContributionContainerEnvName, Value: cheCodeContributionContainerEnvValue},
-		},
-	},
-}
-
-var cheCodeContribution = dwv1alpha2.ComponentContribution{
-	Name: cheCodeContributionName,
-	PluginComponent: dwv1alpha2.PluginComponent{
-		ImportReference: dwv1alpha2.ImportReference{
-			ImportReferenceUnion: dwv1alpha2.ImportReferenceUnion{
-				Uri: cheCodeContributionURI,
-			},
-		},
-		PluginOverrides: dwv1alpha2.PluginOverrides{
-			Components: []dwv1alpha2.ComponentPluginOverride{
-				{
-					Name: cheCodeContributionComponentName,
-					ComponentUnionPluginOverride: dwv1alpha2.ComponentUnionPluginOverride{
-						Container: &cheCodeContainer,
-					},
-				},
-			},
-			Commands: nil,
-		},
-	},
-}
-
 func generate(o DebugIDEOptions) (dwv1alpha2.DevWorkspace, error) {
 	t, err := template(o)
 	if err != nil {
 		return dwv1alpha2.DevWorkspace{}, err
 	}
-	c, err := contribution()
+	name := o.devWorkspaceName()
+	c, err := contribution(o.ide, name)
 	if err != nil {
 		return dwv1alpha2.DevWorkspace{}, err
 	}
@@ -77,7 +49,9 @@ func generate(o DebugIDEOptions) (dwv1alpha2.DevWorkspace, error) {
 			APIVersion: apiVersion,
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name: o.targetPodName + nameSuffix,
+			Name:        name,
+			Labels:      sessionLabels(),
+			Annotations: sessionAnnotations(o),
 		},
 		Spec: dwv1alpha2.DevWorkspaceSpec{
 			Started:       true,
@@ -85,9 +59,19 @@ func generate(o DebugIDEOptions) (dwv1alpha2.DevWorkspace, error) {
 			Contributions: []dwv1alpha2.ComponentContribution{c},
 		},
 	}
+	normalize(&d)
 	return d, nil
 }
 
+// devWorkspaceName is the name of the DevWorkspace of the debugging
+// session, --copy-to or else the target Pod name with the -dw suffix
+func (o DebugIDEOptions) devWorkspaceName() string {
+	if len(o.copyToPodName) > 0 {
+		return o.copyToPodName
+	}
+	return o.targetPodName + nameSuffix
+}
+
 func template(o DebugIDEOptions) (dwv1alpha2.DevWorkspaceTemplateSpec, error) {
 	c, err := templateContent(o)
 	if err != nil {
@@ -100,11 +84,13 @@ func template(o DebugIDEOptions) (dwv1alpha2.DevWorkspaceTemplateSpec, error) {
 }
 
 func templateContent(o DebugIDEOptions) (dwv1alpha2.DevWorkspaceTemplateSpecContent, error) {
-	// For now only one git repository is supported,
-	// but we may support multiple ones in the future
-	gitRepos := []string{o.gitRepository}
-	dwProjects := make([]dwv1alpha2.Project, 0)
-	for _, repo := range gitRepos {
+	// The git@ and ssh:// repositories are cloned by the CDE container
+	// when the session has an ssh key
+	dwProjects := make([]dwv1alpha2.Project, 0, len(o.gitRepositories))
+	for _, repo := range o.gitRepositories {
+		if len(o.gitSSHPrivateKey) > 0 && isSSHRemote(repo.remote) {
+			continue
+		}
 		p, err := project(repo)
 		if err != nil {
 			return dwv1alpha2.DevWorkspaceTemplateSpecContent{}, err
@@ -115,17 +101,80 @@ func templateContent(o DebugIDEOptions) (dwv1alpha2.DevWorkspaceTemplateSpecCont
 	// Add the CDE container
 	dwComponents := make([]dwv1alpha2.Component, 0)
 	c := cdeContainer(o.debugImage)
+	var dwCommands []dwv1alpha2.Command
+	var dwEvents *dwv1alpha2.Events
+	if o.ssh {
+		addSSHEndpoint(&c)
+		dwCommands = append(dwCommands, sshdCommand())
+	}
+	if len(o.gitSSHPrivateKey) > 0 {
+		cmd, err := gitSSHCloneCommand(o.gitRepositories)
+		if err != nil {
+			return dwv1alpha2.DevWorkspaceTemplateSpecContent{}, err
+		}
+		dwCommands = append(dwCommands, cmd)
+	}
+	if mounts := o.sessionVolumeMounts(); len(mounts) > 0 {
+		if err := addContainerOverrides(&c, map[string]interface{}{"volumeMounts": mounts}); err != nil {
+			return dwv1alpha2.DevWorkspaceTemplateSpecContent{}, err
+		}
+	}
+	if debuggerNeedsPtrace(o.debugLanguage) {
+		if err := addPtraceOverrides(&c, o.debuggerRunAsUser()); err != nil {
+			return dwv1alpha2.DevWorkspaceTemplateSpecContent{}, err
+		}
+	}
+	if len(o.launchJSON) > 0 {
+		dwCommands = append(dwCommands, launchCommand(o.debugProjectDir(), o.launchJSON))
+	}
+	postStart := make([]string, 0, len(dwCommands))
+	for _, cmd := range dwCommands {
+		postStart = append(postStart, cmd.Id)
+	}
 	dwComponents = append(dwComponents, c)
 
 	// Add the Pod containers
 	containers := o.targetPodContainers
+	volumes := devfileVolumes(o.copiedContainers())
 	for _, ctr := range containers {
-		c := container(ctr)
+		c, err := container(ctr, o.devWorkspaceName(), volumes)
+		if err != nil {
+			return dwv1alpha2.DevWorkspaceTemplateSpecContent{}, err
+		}
+		dwComponents = append(dwComponents, c)
+	}
+
+	// Add the Pod init containers, the apply commands bound to the
+	// preStart event make them init containers of the DevWorkspace Pod
+	preStart := make([]string, 0, len(o.targetPodInitContainers))
+	for _, ctr := range o.targetPodInitContainers {
+		c, err := initContainer(ctr, o.devWorkspaceName(), volumes)
+		if err != nil {
+			return dwv1alpha2.DevWorkspaceTemplateSpecContent{}, err
+		}
 		dwComponents = append(dwComponents, c)
+		dwCommands = append(dwCommands, applyCommand(ctr.name))
+		preStart = append(preStart, ctr.name)
+	}
+	if len(preStart) > 0 || len(postStart) > 0 {
+		dwEvents = &dwv1alpha2.Events{
+			DevWorkspaceEvents: dwv1alpha2.DevWorkspaceEvents{
+				PreStart:  preStart,
+				PostStart: postStart,
+			},
+		}
+	}
+
+	// Add the Pod emptyDir volumes, the other
+	// volumes are added through the pod-overrides
+	for _, vol := range o.targetPodVolumes {
+		if volumes[vol.Name] {
+			dwComponents = append(dwComponents, ephemeralVolume(vol))
+		}
 	}
 
 	// Add the attributes
-	dwAttributes, err := attributes()
+	dwAttributes, err := attributes(o)
 	if err != nil {
 		return dwv1alpha2.DevWorkspaceTemplateSpecContent{}, err
 	}
@@ -134,23 +183,132 @@ func templateContent(o DebugIDEOptions) (dwv1alpha2.DevWorkspaceTemplateSpecCont
 		Attributes: dwAttributes,
 		Components: dwComponents,
 		Projects:   dwProjects,
+		Commands:   dwCommands,
+		Events:     dwEvents,
 	}
 
 	return tc, nil
 }
 
-func attributes() (devfileattributes.Attributes, error) {
+func attributes(o DebugIDEOptions) (devfileattributes.Attributes, error) {
 	b := []byte(defaultDevWorkspaceAttributes)
 	a := new(devfileattributes.Attributes)
 	if err := a.UnmarshalJSON(b); err != nil {
 		return devfileattributes.Attributes{}, err
 	}
+
+	// The volumes that aren't devfile volumes (ConfigMaps, Secrets,
+	// PVCs...) and the volumes of the session are added to the Pod spec
+	devfileVols := devfileVolumes(o.copiedContainers())
+	volumes := make([]corev1.Volume, 0, len(o.targetPodVolumes))
+	for _, vol := range o.targetPodVolumes {
+		if !devfileVols[vol.Name] {
+			volumes = append(volumes, vol)
+		}
+	}
+	for _, v := range o.sessionVolumes() {
+		volumes = append(volumes, v.volume)
+	}
+
+	if !o.shareProcesses && !o.sameNode && len(volumes) == 0 {
+		return *a, nil
+	}
+
+	podOverrides := map[string]interface{}{}
+	if a.Exists(podOverridesAttribute) {
+		if err := a.GetInto(podOverridesAttribute, &podOverrides); err != nil {
+			return devfileattributes.Attributes{}, err
+		}
+	}
+	spec, ok := podOverrides["spec"].(map[string]interface{})
+	if !ok {
+		spec = map[string]interface{}{}
+	}
+	if o.shareProcesses {
+		spec["shareProcessNamespace"] = true
+	}
+	if len(volumes) > 0 {
+		spec["volumes"] = volumes
+	}
+	if o.sameNode && o.targetPod != nil {
+		spec["affinity"] = sameNodeAffinity(o.targetPod.Spec.NodeName)
+	}
+	podOverrides["spec"] = spec
+
+	var err error
+	a.Put(podOverridesAttribute, podOverrides, &err)
+	if err != nil {
+		return devfileattributes.Attributes{}, err
+	}
 	return *a, nil
 }
 
-func project(remote string) (dwv1alpha2.Project, error) {
+// sessionVolume is a volume of a Secret of the session, added to the
+// pod-overrides and mounted in the CDE container only
+type sessionVolume struct {
+	volume corev1.Volume
+	mount  corev1.VolumeMount
+}
+
+// sessionVolumes returns the volumes of the Secrets of the session
+func (o DebugIDEOptions) sessionVolumes() []sessionVolume {
+	var volumes []sessionVolume
+	if o.ssh {
+		volumes = append(volumes, sshKeysVolume(o.devWorkspaceName()))
+	}
+	if len(o.gitSSHPrivateKey) > 0 {
+		volumes = append(volumes, gitSSHKeyVolume(o.devWorkspaceName()))
+	}
+	return volumes
+}
+
+func (o DebugIDEOptions) sessionVolumeMounts() []corev1.VolumeMount {
+	var mounts []corev1.VolumeMount
+	for _, v := range o.sessionVolumes() {
+		mounts = append(mounts, v.mount)
+	}
+	return mounts
+}
+
+// sameNodeAffinity requires the Pod to be scheduled on the node nodeName
+func sameNodeAffinity(nodeName string) corev1.Affinity {
+	return corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+					MatchFields: []corev1.NodeSelectorRequirement{{
+						Key:      "metadata.name",
+						Operator: corev1.NodeSelectorOpIn,
+						Values:   []string{nodeName},
+					}},
+				}},
+			},
+		},
+	}
+}
+
+// addContainerOverrides merges overrides in the container-overrides attribute of a component
+func addContainerOverrides(c *dwv1alpha2.Component, overrides map[string]interface{}) error {
+	merged := map[string]interface{}{}
+	if c.Attributes.Exists(containerOverridesAttribute) {
+		if err := c.Attributes.GetInto(containerOverridesAttribute, &merged); err != nil {
+			return err
+		}
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	if c.Attributes == nil {
+		c.Attributes = devfileattributes.Attributes{}
+	}
+	var err error
+	c.Attributes.Put(containerOverridesAttribute, merged, &err)
+	return err
+}
+
+func project(repo gitRepository) (dwv1alpha2.Project, error) {
 	p := dwv1alpha2.Project{}
-	name, err := projectName(remote)
+	name, err := projectName(repo.remote)
 	if err != nil {
 		return p, err
 	}
@@ -161,9 +319,15 @@ func project(remote string) (dwv1alpha2.Project, error) {
 	g := dwv1alpha2.GitLikeProjectSource{
 		CommonProjectSource: dwv1alpha2.CommonProjectSource{},
 		Remotes: map[string]string{
-			defaultRemoteName: remote,
+			defaultRemoteName: repo.remote,
 		},
 	}
+	if repo.revision != "" {
+		g.CheckoutFrom = &dwv1alpha2.CheckoutFrom{
+			Remote:   defaultRemoteName,
+			Revision: repo.revision,
+		}
+	}
 	gg := dwv1alpha2.GitProjectSource{GitLikeProjectSource: g}
 	p.ProjectSource.Git = gg.DeepCopy()
 	return p, nil
@@ -202,17 +366,57 @@ func cdeContainer(image string) dwv1alpha2.Component {
 	return comp
 }
 
-func container(ctr ContainerInfo) dwv1alpha2.Component {
+func ephemeralVolume(vol corev1.Volume) dwv1alpha2.Component {
+	ephemeral := true
+	v := dwv1alpha2.Volume{
+		Ephemeral: &ephemeral,
+	}
+	if vol.EmptyDir.SizeLimit != nil {
+		v.Size = vol.EmptyDir.SizeLimit.String()
+	}
+	return dwv1alpha2.Component{
+		Name: vol.Name,
+		ComponentUnion: dwv1alpha2.ComponentUnion{
+			Volume: &dwv1alpha2.VolumeComponent{
+				Volume: v,
+			},
+		},
+	}
+}
+
+// container is the component of a container of the Pod. The DevWorkspace
+// Operator rejects the env of the container-overrides: the env variables
+// that reference a ConfigMap or a Secret are imported from the copies of
+// the session, see envCopies, and the ones that reference the Pod fields
+// are skipped. The devfile volumes are mounted by the component, the
+// other volumes through the container-overrides.
+func container(ctr ContainerInfo, dwName string, devfileVolumes map[string]bool) (dwv1alpha2.Component, error) {
+	var fromConfigMap, fromSecret bool
 	vars := make([]dwv1alpha2.EnvVar, 0, len(ctr.env))
 	for _, env := range ctr.env {
+		if env.valueFrom != nil {
+			fromConfigMap = fromConfigMap || env.valueFrom.ConfigMapKeyRef != nil
+			fromSecret = fromSecret || env.valueFrom.SecretKeyRef != nil
+			continue
+		}
 		v := dwv1alpha2.EnvVar{
 			Name:  env.name,
 			Value: env.value,
 		}
 		vars = append(vars, v)
 	}
+	var overrideMounts []corev1.VolumeMount
 	vols := make([]dwv1alpha2.VolumeMount, 0, len(ctr.volumes))
 	for _, vol := range ctr.volumes {
+		if !devfileVolumes[vol.name] {
+			overrideMounts = append(overrideMounts, corev1.VolumeMount{
+				Name:      vol.name,
+				MountPath: vol.path,
+				SubPath:   vol.subPath,
+				ReadOnly:  vol.readOnly,
+			})
+			continue
+		}
 		v := dwv1alpha2.VolumeMount{
 			Name: vol.name,
 			Path: vol.path,
@@ -221,16 +425,7 @@ func container(ctr ContainerInfo) dwv1alpha2.Component {
 	}
 	ends := make([]dwv1alpha2.Endpoint, 0, len(ctr.endpoints))
 	for _, end := range ctr.endpoints {
-		secure := defaultEndpointSecure
-		e := dwv1alpha2.Endpoint{
-			Name:       end.name,
-			TargetPort: end.targetPort,
-			Exposure:   defaultEndpointExposure,
-			Protocol:   defaultEndpointProtocol,
-			Secure:     &secure,
-			Path:       defaultEndpointPath,
-		}
-		ends = append(ends, e)
+		ends = append(ends, endpoint(end))
 	}
 
 	c := dwv1alpha2.Container{
@@ -253,10 +448,229 @@ func container(ctr ContainerInfo) dwv1alpha2.Component {
 			},
 		},
 	}
-	return comp
+
+	// The copies come last, the env variables take
+	// precedence over the envFrom sources of the Pod
+	envFrom := append([]corev1.EnvFromSource{}, ctr.envFrom...)
+	if fromConfigMap {
+		envFrom = append(envFrom, corev1.EnvFromSource{
+			ConfigMapRef: &corev1.ConfigMapEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: envCopyName(dwName, ctr.name)},
+			},
+		})
+	}
+	if fromSecret {
+		envFrom = append(envFrom, corev1.EnvFromSource{
+			SecretRef: &corev1.SecretEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: envCopyName(dwName, ctr.name)},
+			},
+		})
+	}
+
+	overrides := map[string]interface{}{}
+	if len(envFrom) > 0 {
+		overrides["envFrom"] = envFrom
+	}
+	if len(overrideMounts) > 0 {
+		overrides["volumeMounts"] = overrideMounts
+	}
+	// The probes are not set with --disable-probes, see completeTargetContainers
+	if ctr.livenessProbe != nil {
+		overrides["livenessProbe"] = ctr.livenessProbe
+	}
+	if ctr.readinessProbe != nil {
+		overrides["readinessProbe"] = ctr.readinessProbe
+	}
+	if ctr.startupProbe != nil {
+		overrides["startupProbe"] = ctr.startupProbe
+	}
+	if ctr.lifecycle != nil {
+		overrides["lifecycle"] = ctr.lifecycle
+	}
+	if len(overrides) > 0 {
+		var err error
+		comp.Attributes = devfileattributes.Attributes{}.Put(containerOverridesAttribute, overrides, &err)
+		if err != nil {
+			return dwv1alpha2.Component{}, err
+		}
+	}
+	return comp, nil
+}
+
+// endpoint maps a port of the Pod to a devfile endpoint. The application
+// protocol is told by the port name, following the <protocol>[-<suffix>]
+// convention of Istio (e.g. http-api). The TCP ports are public HTTP
+// endpoints unless their name tells a protocol that isn't HTTP (e.g.
+// tcp, mysql): these ports, and the UDP and SCTP ones, are internal.
+func endpoint(end ContainerEndpoint) dwv1alpha2.Endpoint {
+	secure := defaultEndpointSecure
+	e := dwv1alpha2.Endpoint{
+		Name:       end.name,
+		TargetPort: end.targetPort,
+		Exposure:   defaultEndpointExposure,
+		Protocol:   defaultEndpointProtocol,
+		Secure:     &secure,
+		Path:       defaultEndpointPath,
+	}
+
+	name := strings.ToLower(end.name)
+	switch {
+	case end.protocol == corev1.ProtocolUDP:
+		e.Protocol = dwv1alpha2.UDPEndpointProtocol
+	case end.protocol == corev1.ProtocolSCTP:
+		// SCTP has no devfile equivalent
+		e.Protocol = dwv1alpha2.TCPEndpointProtocol
+	case hasProtocolPrefix(name, "https", "h2"):
+		e.Protocol = dwv1alpha2.HTTPSEndpointProtocol
+	case hasProtocolPrefix(name, "http", "http2", "h2c", "grpc", "grpc-web"):
+		// gRPC and cleartext HTTP/2 have no devfile protocol, they are HTTP
+		e.Protocol = dwv1alpha2.HTTPEndpointProtocol
+	case hasProtocolPrefix(name, "wss"):
+		e.Protocol = dwv1alpha2.WSSEndpointProtocol
+	case hasProtocolPrefix(name, "ws"):
+		e.Protocol = dwv1alpha2.WSEndpointProtocol
+	case hasProtocolPrefix(name, nonHTTPProtocols...):
+		e.Protocol = dwv1alpha2.TCPEndpointProtocol
+	}
+	if e.Protocol == dwv1alpha2.TCPEndpointProtocol || e.Protocol == dwv1alpha2.UDPEndpointProtocol {
+		e.Exposure = dwv1alpha2.InternalEndpointExposure
+		e.Path = ""
+	}
+	return e
+}
+
+// nonHTTPProtocols are the port names of the protocols that aren't HTTP,
+// the ones of Istio and of the common databases and message brokers
+var nonHTTPProtocols = []string{
+	"tcp", "tls", "udp", "mongo", "mysql", "redis", "postgres", "postgresql",
+	"amqp", "kafka", "mqtt", "nats", "memcached", "zookeeper", "ssh", "dns", "ldap", "smtp",
+}
+
+// hasProtocolPrefix is true if name is one of the protocols
+// or starts with one of them followed by a dash
+func hasProtocolPrefix(name string, protocols ...string) bool {
+	for _, p := range protocols {
+		if name == p || strings.HasPrefix(name, p+"-") {
+			return true
+		}
+	}
+	return false
 }
 
-func contribution() (dwv1alpha2.ComponentContribution, error) {
-	c := cheCodeContribution
+// initContainer is the component of an init container of the Pod. It has
+// no endpoints as it runs to completion before the other containers.
+func initContainer(ctr ContainerInfo, dwName string, devfileVolumes map[string]bool) (dwv1alpha2.Component, error) {
+	ctr.endpoints = nil
+	return container(ctr, dwName, devfileVolumes)
+}
+
+// applyCommand runs the component as an init container. The command is
+// named after the component, see validateDevWorkspaceNames.
+func applyCommand(component string) dwv1alpha2.Command {
+	return dwv1alpha2.Command{
+		Id: component,
+		CommandUnion: dwv1alpha2.CommandUnion{
+			Apply: &dwv1alpha2.ApplyCommand{
+				Component: component,
+			},
+		},
+	}
+}
+
+// validateDevWorkspaceNames returns an error if two components, or two
+// commands, of the DevWorkspace have the same name: the containers, the
+// init containers and the devfile volumes of the Pod are components
+// named after them, and the init containers are run by commands too.
+func validateDevWorkspaceNames(o DebugIDEOptions) error {
+	components := map[string]string{defaultDevContainerName: "debugging container"}
+	commands := map[string]string{}
+	if o.ssh {
+		commands[sshdCommandID] = "command"
+	}
+	if len(o.gitSSHPrivateKey) > 0 {
+		commands[gitSSHCloneCommandID] = "command"
+	}
+	if len(o.launchJSON) > 0 {
+		commands[launchCommandID] = "command"
+	}
+	add := func(names map[string]string, kind, name string) error {
+		if other, ok := names[name]; ok {
+			return fmt.Errorf("the %s %s and the %s %s cannot have the same name in a DevWorkspace: use --backend native",
+				other, name, kind, name)
+		}
+		names[name] = kind
+		return nil
+	}
+
+	for _, c := range o.targetPodContainers {
+		if err := add(components, "container", c.name); err != nil {
+			return err
+		}
+	}
+	for _, c := range o.targetPodInitContainers {
+		if err := add(components, "init container", c.name); err != nil {
+			return err
+		}
+		if err := add(commands, "init container", c.name); err != nil {
+			return err
+		}
+	}
+	volumes := map[string]string{}
+	for _, v := range o.sessionVolumes() {
+		volumes[v.volume.Name] = "session volume"
+	}
+	devfileVols := devfileVolumes(o.copiedContainers())
+	for _, v := range o.targetPodVolumes {
+		if err := add(volumes, "volume", v.Name); err != nil {
+			return err
+		}
+		if !devfileVols[v.Name] {
+			continue
+		}
+		if err := add(components, "volume", v.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func contribution(ide ideDefinition, dwName string) (dwv1alpha2.ComponentContribution, error) {
+	ref := ide.importReference
+	if ide.template != nil {
+		ref = dwv1alpha2.ImportReference{
+			ImportReferenceUnion: dwv1alpha2.ImportReferenceUnion{
+				Kubernetes: &dwv1alpha2.KubernetesCustomResourceImportReference{
+					Name: ideTemplateName(dwName),
+				},
+			},
+		}
+	}
+	if ref.Uri == "" && ref.Id == "" && ref.Kubernetes == nil {
+		return dwv1alpha2.ComponentContribution{}, errors.New("the IDE reference is empty")
+	}
+
+	var overrides []dwv1alpha2.ComponentPluginOverride
+	if len(ide.env) > 0 {
+		overrides = append(overrides, dwv1alpha2.ComponentPluginOverride{
+			Name: ide.runtimeComponent,
+			ComponentUnionPluginOverride: dwv1alpha2.ComponentUnionPluginOverride{
+				Container: &dwv1alpha2.ContainerComponentPluginOverride{
+					ContainerPluginOverride: dwv1alpha2.ContainerPluginOverride{
+						Env: ide.env,
+					},
+				},
+			},
+		})
+	}
+
+	c := dwv1alpha2.ComponentContribution{
+		Name: ide.name,
+		PluginComponent: dwv1alpha2.PluginComponent{
+			ImportReference: ref,
+			PluginOverrides: dwv1alpha2.PluginOverrides{
+				Components: overrides,
+			},
+		},
+	}
 	return c, nil
 }
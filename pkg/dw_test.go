@@ -1,10 +1,18 @@
 package pkg
 
 import (
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
+	dwv1alpha2 "github.com/devfile/api/v2/pkg/apis/workspaces/v1alpha2"
 	attributes2 "github.com/devfile/api/v2/pkg/attributes"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
 )
 
 func Test_projectName(t *testing.T) {
@@ -57,19 +65,94 @@ func Test_projectName(t *testing.T) {
 	}
 }
 
+func Test_project(t *testing.T) {
+	tests := []struct {
+		name string
+		repo gitRepository
+		want *dwv1alpha2.CheckoutFrom
+	}{
+		{
+			name: "default branch",
+			repo: gitRepository{remote: "https://github.com/l0rd/outyet.git"},
+		},
+		{
+			name: "commit",
+			repo: gitRepository{remote: "https://github.com/l0rd/outyet.git", revision: "4f3e2a1"},
+			want: &dwv1alpha2.CheckoutFrom{Remote: defaultRemoteName, Revision: "4f3e2a1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := project(tt.repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Name != "outyet" || got.Git.Remotes[defaultRemoteName] != tt.repo.remote {
+				t.Errorf("project() = %v, want project outyet with remote %s", got, tt.repo.remote)
+			}
+			if !reflect.DeepEqual(got.Git.CheckoutFrom, tt.want) {
+				t.Errorf("project() checkoutFrom = %v, want %v", got.Git.CheckoutFrom, tt.want)
+			}
+		})
+	}
+}
+
 func Test_attributes(t *testing.T) {
+	uid := int64(1001)
 	tests := []struct {
 		name string
+		o    DebugIDEOptions
 		want []byte
 	}{
 		{
 			name: "default attributes generation",
 			want: []byte(defaultDevWorkspaceAttributes),
 		},
+		{
+			name: "share processes",
+			o:    DebugIDEOptions{shareProcesses: true},
+			want: []byte(`{"controller.devfile.io/storage-type":"ephemeral","pod-overrides":{"spec":{"shareProcessNamespace":true}}}`),
+		},
+		{
+			name: "same node",
+			o: DebugIDEOptions{
+				sameNode:  true,
+				targetPod: &corev1.Pod{Spec: corev1.PodSpec{NodeName: "worker-1"}},
+			},
+			want: []byte(`{"controller.devfile.io/storage-type":"ephemeral","pod-overrides":{"spec":{"affinity":{"nodeAffinity":{"requiredDuringSchedulingIgnoredDuringExecution":{"nodeSelectorTerms":[{"matchFields":[{"key":"metadata.name","operator":"In","values":["worker-1"]}]}]}}}}}}`),
+		},
+		{
+			name: "ssh",
+			o:    DebugIDEOptions{targetPodName: "outyet", ssh: true},
+			want: []byte(`{"controller.devfile.io/storage-type":"ephemeral","pod-overrides":{"spec":{"volumes":[{"name":"debug-ide-ssh-keys","secret":{"secretName":"outyet-dw-ssh"}}]}}}`),
+		},
+		{
+			name: "ptrace debugger keeps the Pod user",
+			o: DebugIDEOptions{
+				shareProcesses: true,
+				debugLanguage:  debuggerGo,
+				debugContainer: "outyet",
+				targetPod: &corev1.Pod{Spec: corev1.PodSpec{
+					SecurityContext: &corev1.PodSecurityContext{RunAsUser: &uid},
+				}},
+			},
+			want: []byte(`{"controller.devfile.io/storage-type":"ephemeral","pod-overrides":{"spec":{"shareProcessNamespace":true}}}`),
+		},
+		{
+			name: "socket debugger",
+			o: DebugIDEOptions{
+				debugLanguage:  debuggerJava,
+				debugContainer: "outyet",
+				targetPod: &corev1.Pod{Spec: corev1.PodSpec{
+					SecurityContext: &corev1.PodSecurityContext{RunAsUser: &uid},
+				}},
+			},
+			want: []byte(defaultDevWorkspaceAttributes),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, _ := attributes()
+			got, _ := attributes(tt.o)
 			var wantAttr attributes2.Attributes
 			err := wantAttr.UnmarshalJSON(tt.want)
 			if err != nil {
@@ -82,3 +165,315 @@ func Test_attributes(t *testing.T) {
 		})
 	}
 }
+
+func Test_container(t *testing.T) {
+	secretRef := &corev1.EnvVarSource{
+		SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "db"},
+			Key:                  "password",
+		},
+	}
+	envFrom := []corev1.EnvFromSource{
+		{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "config"}}},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "outyet"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:    "outyet",
+					Image:   "ghcr.io/l0rd/outyet:latest",
+					Command: []string{"/outyet"},
+					Args:    []string{"-version", "1.23"},
+					Env: []corev1.EnvVar{
+						{Name: "MODE", Value: "debug"},
+						{Name: "DB_PASSWORD", ValueFrom: secretRef},
+					},
+					EnvFrom: envFrom,
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")},
+					},
+					VolumeMounts: []corev1.VolumeMount{
+						{Name: "cache", MountPath: "/cache"},
+						{Name: "config", MountPath: "/etc/outyet", ReadOnly: true},
+						{Name: "kube-api-access", MountPath: serviceAccountMountPath, ReadOnly: true},
+					},
+				},
+			},
+			Volumes: []corev1.Volume{
+				{Name: "cache", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+				{Name: "config", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "config"},
+				}}},
+				{Name: "kube-api-access", VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{}}},
+			},
+		},
+	}
+
+	infos := podContainers(pod, true)
+	if len(infos) != 1 {
+		t.Fatalf("podContainers() returned %d containers, want 1", len(infos))
+	}
+	if vols := podVolumes(pod, infos); len(vols) != 2 {
+		t.Errorf("podVolumes() returned %d volumes, want 2", len(vols))
+	}
+
+	got, err := container(infos[0], "outyet-dw", devfileVolumes(infos))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := got.Container
+	if !reflect.DeepEqual(c.Command, []string{"/outyet"}) || !reflect.DeepEqual(c.Args, []string{"-version", "1.23"}) {
+		t.Errorf("container() command = %v args = %v", c.Command, c.Args)
+	}
+	if c.MemoryRequest != "64Mi" || c.MemoryLimit != "" {
+		t.Errorf("container() memoryRequest = %q memoryLimit = %q", c.MemoryRequest, c.MemoryLimit)
+	}
+	wantEnv := []dwv1alpha2.EnvVar{{Name: "MODE", Value: "debug"}}
+	if !reflect.DeepEqual(c.Env, wantEnv) {
+		t.Errorf("container() env = %v, want %v", c.Env, wantEnv)
+	}
+	wantMounts := []dwv1alpha2.VolumeMount{{Name: "cache", Path: "/cache"}}
+	if !reflect.DeepEqual(c.VolumeMounts, wantMounts) {
+		t.Errorf("container() volumeMounts = %v, want %v", c.VolumeMounts, wantMounts)
+	}
+
+	var overrides struct {
+		Env          []corev1.EnvVar        `json:"env"`
+		EnvFrom      []corev1.EnvFromSource `json:"envFrom"`
+		VolumeMounts []corev1.VolumeMount   `json:"volumeMounts"`
+	}
+	if err := got.Attributes.GetInto(containerOverridesAttribute, &overrides); err != nil {
+		t.Fatal(err)
+	}
+	if len(overrides.Env) > 0 {
+		t.Errorf("container() overrides env = %v, rejected by the DevWorkspace Operator", overrides.Env)
+	}
+	wantEnvFrom := append(envFrom, corev1.EnvFromSource{
+		SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "outyet-dw-outyet-env"}},
+	})
+	if !reflect.DeepEqual(overrides.EnvFrom, wantEnvFrom) {
+		t.Errorf("container() overrides envFrom = %v, want %v", overrides.EnvFrom, wantEnvFrom)
+	}
+	wantOverrideMounts := []corev1.VolumeMount{{Name: "config", MountPath: "/etc/outyet", ReadOnly: true}}
+	if !reflect.DeepEqual(overrides.VolumeMounts, wantOverrideMounts) {
+		t.Errorf("container() overrides volumeMounts = %v, want %v", overrides.VolumeMounts, wantOverrideMounts)
+	}
+}
+
+func Test_devWorkspaceName(t *testing.T) {
+	tests := []struct {
+		name string
+		o    DebugIDEOptions
+		want string
+	}{
+		{name: "target pod name", o: DebugIDEOptions{targetPodName: "outyet"}, want: "outyet-dw"},
+		{name: "copy-to", o: DebugIDEOptions{targetPodName: "outyet", copyToPodName: "outyet-debug"}, want: "outyet-debug"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.o.devWorkspaceName(); got != tt.want {
+				t.Errorf("devWorkspaceName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_podInitContainers(t *testing.T) {
+	always := corev1.ContainerRestartPolicyAlways
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		InitContainers: []corev1.Container{
+			{Name: "migrate", Image: "migrate"},
+			{Name: "mesh-proxy", Image: "proxy", RestartPolicy: &always},
+			{Name: "render-config", Image: "render"},
+		},
+		Containers: []corev1.Container{{Name: "app", Image: "app"}},
+	}}
+
+	names := func(infos []ContainerInfo) []string {
+		var n []string
+		for _, i := range infos {
+			n = append(n, i.name)
+		}
+		return n
+	}
+	if got, want := names(podInitContainers(pod)), []string{"migrate", "render-config"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("podInitContainers() = %v, want %v", got, want)
+	}
+	if got, want := names(podContainers(pod, true)), []string{"mesh-proxy", "app"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("podContainers() with sidecars = %v, want %v", got, want)
+	}
+	if got, want := names(podContainers(pod, false)), []string{"app"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("podContainers() without sidecars = %v, want %v", got, want)
+	}
+}
+
+func Test_endpoint(t *testing.T) {
+	tests := []struct {
+		name         string
+		end          ContainerEndpoint
+		wantProtocol dwv1alpha2.EndpointProtocol
+		wantExposure dwv1alpha2.EndpointExposure
+	}{
+		{"unnamed port", ContainerEndpoint{name: "port8080", protocol: corev1.ProtocolTCP}, dwv1alpha2.HTTPEndpointProtocol, dwv1alpha2.PublicEndpointExposure},
+		{"unknown name", ContainerEndpoint{name: "api", protocol: corev1.ProtocolTCP}, dwv1alpha2.HTTPEndpointProtocol, dwv1alpha2.PublicEndpointExposure},
+		{"unknown prefix", ContainerEndpoint{name: "metrics-http", protocol: corev1.ProtocolTCP}, dwv1alpha2.HTTPEndpointProtocol, dwv1alpha2.PublicEndpointExposure},
+		{"http", ContainerEndpoint{name: "http", protocol: corev1.ProtocolTCP}, dwv1alpha2.HTTPEndpointProtocol, dwv1alpha2.PublicEndpointExposure},
+		{"http with suffix", ContainerEndpoint{name: "HTTP-web", protocol: corev1.ProtocolTCP}, dwv1alpha2.HTTPEndpointProtocol, dwv1alpha2.PublicEndpointExposure},
+		{"https", ContainerEndpoint{name: "https", protocol: corev1.ProtocolTCP}, dwv1alpha2.HTTPSEndpointProtocol, dwv1alpha2.PublicEndpointExposure},
+		{"h2", ContainerEndpoint{name: "h2-api", protocol: corev1.ProtocolTCP}, dwv1alpha2.HTTPSEndpointProtocol, dwv1alpha2.PublicEndpointExposure},
+		{"websocket", ContainerEndpoint{name: "ws-events", protocol: corev1.ProtocolTCP}, dwv1alpha2.WSEndpointProtocol, dwv1alpha2.PublicEndpointExposure},
+		{"secure websocket", ContainerEndpoint{name: "wss", protocol: corev1.ProtocolTCP}, dwv1alpha2.WSSEndpointProtocol, dwv1alpha2.PublicEndpointExposure},
+		{"grpc", ContainerEndpoint{name: "GRPC-api", protocol: corev1.ProtocolTCP}, dwv1alpha2.HTTPEndpointProtocol, dwv1alpha2.PublicEndpointExposure},
+		{"grpc-web", ContainerEndpoint{name: "grpc-web", protocol: corev1.ProtocolTCP}, dwv1alpha2.HTTPEndpointProtocol, dwv1alpha2.PublicEndpointExposure},
+		{"http2", ContainerEndpoint{name: "http2", protocol: corev1.ProtocolTCP}, dwv1alpha2.HTTPEndpointProtocol, dwv1alpha2.PublicEndpointExposure},
+		{"h2c", ContainerEndpoint{name: "h2c", protocol: corev1.ProtocolTCP}, dwv1alpha2.HTTPEndpointProtocol, dwv1alpha2.PublicEndpointExposure},
+		{"tcp", ContainerEndpoint{name: "tcp-db", protocol: corev1.ProtocolTCP}, dwv1alpha2.TCPEndpointProtocol, dwv1alpha2.InternalEndpointExposure},
+		{"database", ContainerEndpoint{name: "postgres", protocol: corev1.ProtocolTCP}, dwv1alpha2.TCPEndpointProtocol, dwv1alpha2.InternalEndpointExposure},
+		{"not a prefix", ContainerEndpoint{name: "tcpdump", protocol: corev1.ProtocolTCP}, dwv1alpha2.HTTPEndpointProtocol, dwv1alpha2.PublicEndpointExposure},
+		{"udp", ContainerEndpoint{name: "dns", protocol: corev1.ProtocolUDP}, dwv1alpha2.UDPEndpointProtocol, dwv1alpha2.InternalEndpointExposure},
+		{"sctp", ContainerEndpoint{name: "http", protocol: corev1.ProtocolSCTP}, dwv1alpha2.TCPEndpointProtocol, dwv1alpha2.InternalEndpointExposure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := endpoint(tt.end)
+			if got.Protocol != tt.wantProtocol || got.Exposure != tt.wantExposure {
+				t.Errorf("endpoint() = %s %s, want %s %s", got.Protocol, got.Exposure, tt.wantProtocol, tt.wantExposure)
+			}
+			if internal := got.Exposure == dwv1alpha2.InternalEndpointExposure; internal != (got.Path == "") {
+				t.Errorf("endpoint() path = %q with exposure %s", got.Path, got.Exposure)
+			}
+		})
+	}
+}
+
+// The port of the demo Pod has no name, its IDE preview must be public
+func Test_endpoint_demoPod(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("..", "demo-assets", "outyet.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := &corev1.Pod{}
+	if err := yaml.Unmarshal([]byte(strings.Split(string(b), "\n---")[0]), pod); err != nil {
+		t.Fatal(err)
+	}
+	infos := podContainers(pod, true)
+	if len(infos) != 1 || len(infos[0].endpoints) != 1 {
+		t.Fatalf("podContainers() = %+v, want a container with a port", infos)
+	}
+	got := endpoint(infos[0].endpoints[0])
+	if got.TargetPort != 8080 || got.Protocol != dwv1alpha2.HTTPEndpointProtocol || got.Exposure != dwv1alpha2.PublicEndpointExposure {
+		t.Errorf("endpoint() = %d %s %s, want 8080 http public", got.TargetPort, got.Protocol, got.Exposure)
+	}
+}
+
+func Test_validateDevWorkspaceNames(t *testing.T) {
+	emptyDir := func(name string) corev1.Volume {
+		return corev1.Volume{Name: name, VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}
+	}
+	containers := []ContainerInfo{{name: "app"}}
+	tests := []struct {
+		name    string
+		o       DebugIDEOptions
+		wantErr string
+	}{
+		{
+			name: "unique names",
+			o: DebugIDEOptions{
+				targetPodContainers:     containers,
+				targetPodInitContainers: []ContainerInfo{{name: "migrate"}},
+				targetPodVolumes:        []corev1.Volume{emptyDir("data")},
+				ssh:                     true,
+				launchJSON:              []byte("{}"),
+			},
+		},
+		{
+			name:    "container named as the debugging container",
+			o:       DebugIDEOptions{targetPodContainers: []ContainerInfo{{name: defaultDevContainerName}}},
+			wantErr: "the debugging container cde and the container cde cannot have the same name",
+		},
+		{
+			name: "init container named as a volume",
+			o: DebugIDEOptions{
+				targetPodContainers: containers,
+				targetPodInitContainers: []ContainerInfo{{
+					name:    "data",
+					volumes: []ContainerVolume{{name: "data", path: "/data", ephemeral: true}},
+				}},
+				targetPodVolumes: []corev1.Volume{emptyDir("data")},
+			},
+			wantErr: "the init container data and the volume data cannot have the same name",
+		},
+		{
+			name: "container named as a volume",
+			o: DebugIDEOptions{
+				targetPodContainers: []ContainerInfo{{
+					name:    "app",
+					volumes: []ContainerVolume{{name: "app", path: "/app", ephemeral: true}},
+				}},
+				targetPodVolumes: []corev1.Volume{emptyDir("app")},
+			},
+			wantErr: "the container app and the volume app cannot have the same name",
+		},
+		{
+			name: "volume of the pod-overrides",
+			o: DebugIDEOptions{
+				targetPodContainers: []ContainerInfo{{
+					name:    "app",
+					volumes: []ContainerVolume{{name: "app", path: "/app"}},
+				}},
+				targetPodVolumes: []corev1.Volume{{Name: "app", VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{},
+				}}},
+			},
+		},
+		{
+			name: "volume named as a session volume",
+			o: DebugIDEOptions{
+				targetPodContainers: containers,
+				targetPodVolumes:    []corev1.Volume{emptyDir(sshKeysVolumeName)},
+				ssh:                 true,
+			},
+			wantErr: "the session volume debug-ide-ssh-keys and the volume debug-ide-ssh-keys cannot have the same name",
+		},
+		{
+			name: "init container named as the sshd command",
+			o: DebugIDEOptions{
+				targetPodContainers:     containers,
+				targetPodInitContainers: []ContainerInfo{{name: sshdCommandID}},
+				ssh:                     true,
+			},
+			wantErr: "the command start-sshd and the init container start-sshd cannot have the same name",
+		},
+		{
+			name: "init container named as the launch command",
+			o: DebugIDEOptions{
+				targetPodContainers:     containers,
+				targetPodInitContainers: []ContainerInfo{{name: launchCommandID}},
+				launchJSON:              []byte("{}"),
+			},
+			wantErr: "the command write-launch-json and the init container write-launch-json cannot have the same name",
+		},
+		{
+			name: "init container named as an unused command",
+			o: DebugIDEOptions{
+				targetPodContainers:     containers,
+				targetPodInitContainers: []ContainerInfo{{name: sshdCommandID}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateDevWorkspaceNames(tt.o)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateDevWorkspaceNames() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("validateDevWorkspaceNames() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+)
+
+const (
+	defaultEphemeralImage        = "quay.io/che-incubator/che-code:latest"
+	ephemeralContainerNamePrefix = "debug-ide-"
+	ephemeralProjectsRoot        = "/projects"
+	ephemeralIDEPort             = 3100
+	// ephemeralIDEBootstrapScript starts che-code, bundled in the image, and
+	// clones the git repository. The errors are written in the termination
+	// message, reported when the container terminates.
+	ephemeralIDEBootstrapScript = `set -e
+fail() {
+  echo "$1" > /dev/termination-log
+  echo "$1" >&2
+  exit 1
+}
+if [ ! -x /checode/entrypoint-volume.sh ]; then
+  [ -x /entrypoint-init-container.sh ] || fail "che-code not found in the image: use an --image based on ` + defaultEphemeralImage + `"
+  /entrypoint-init-container.sh
+fi
+mkdir -p "${PROJECTS_ROOT}"
+if [ -n "${GIT_REPOSITORY}" ] && [ ! -d "${PROJECT_SOURCE}" ]; then
+  command -v git > /dev/null || fail "git not found in the image, required to clone ${GIT_REPOSITORY}"
+  git clone "${GIT_REPOSITORY}" "${PROJECT_SOURCE}"
+  if [ -n "${GIT_REVISION}" ]; then
+    git -C "${PROJECT_SOURCE}" checkout "${GIT_REVISION}"
+  fi
+fi
+exec /checode/entrypoint-volume.sh
+`
+)
+
+// ephemeralContainer returns the IDE container that gets added to the
+// target Pod. Ephemeral containers cannot declare ports nor volumes: the
+// IDE listens on ephemeralIDEPort in the Pod network namespace and the
+// git repository is cloned in the container filesystem.
+func ephemeralContainer(o DebugIDEOptions) (corev1.EphemeralContainer, error) {
+	projectSource := ephemeralProjectsRoot
+	repo := gitRepository{}
+	if len(o.gitRepositories) > 0 {
+		repo = o.gitRepositories[0]
+		name, err := projectName(repo.remote)
+		if err != nil {
+			return corev1.EphemeralContainer{}, err
+		}
+		projectSource = ephemeralProjectsRoot + "/" + name
+	}
+
+	c := corev1.EphemeralContainer{
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+			Name:    ephemeralContainerNamePrefix + utilrand.String(5),
+			Image:   o.debugImage,
+			Command: []string{"/bin/sh", "-c", ephemeralIDEBootstrapScript},
+			Env: []corev1.EnvVar{
+				{Name: cheCodeContributionContainerEnvName, Value: cheCodeContributionContainerEnvValue},
+				{Name: "PROJECTS_ROOT", Value: ephemeralProjectsRoot},
+				{Name: "PROJECT_SOURCE", Value: projectSource},
+				{Name: "GIT_REPOSITORY", Value: repo.remote},
+				{Name: "GIT_REVISION", Value: repo.revision},
+			},
+			WorkingDir:               ephemeralProjectsRoot,
+			TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+			ImagePullPolicy:          corev1.PullIfNotPresent,
+		},
+		TargetContainerName: o.targetContainer,
+	}
+	return c, nil
+}
+
+// runEphemeral adds an IDE ephemeral container to the running target Pod
+// rather than creating a copy of it as a DevWorkspace
+func (o *DebugIDEOptions) runEphemeral(ctx context.Context) error {
+	ec, err := ephemeralContainer(*o)
+	if err != nil {
+		return fmt.Errorf("error generating ephemeral container: %v", err)
+	}
+
+	pod := o.targetPod.DeepCopy()
+	pod.Spec.EphemeralContainers = append(pod.Spec.EphemeralContainers, ec)
+
+	if o.dryRun == dryRunClient {
+		return o.printObjects(withPodTypeMeta(pod))
+	}
+
+	namespace := o.clients.namespace
+	clientset := o.clients.clientset
+	_, err = clientset.CoreV1().Pods(namespace).UpdateEphemeralContainers(
+		ctx,
+		pod.Name,
+		pod,
+		metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("error adding ephemeral container to pod %s: %v", pod.Name, err)
+	}
+	fmt.Fprintf(o.Out, "⌨️ added ephemeral container %s to pod %s in namespace %s.\n", ec.Name, pod.Name, namespace)
+
+	waitCtx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+
+	progress := newProgressReporter(o.Out, time.Now())
+
+	// Report the Events of the ephemeral container while waiting
+	eventsCtx, stopEvents := context.WithCancel(waitCtx)
+	defer stopEvents()
+	go progress.watchEvents(eventsCtx, clientset, namespace, ephemeralEventFilter(pod.Name, ec.Name))
+
+	// Wait for the ephemeral container to be running
+	progress.start("🥑 waiting for the container %s to be running...", ec.Name)
+	if _, err := waitForEphemeralContainer(waitCtx, clientset, namespace, pod.Name, ec.Name, progress); err != nil {
+		return o.waitError(progress, "the container "+ec.Name+" is not running", err)
+	}
+	progress.done("done\n")
+	progress.close()
+
+	if o.local {
+		endpoints := []podEndpoint{{container: ec.Name, name: "ide", port: ephemeralIDEPort}}
+		return o.forwardPorts(ctx, pod.Name, endpoints, ec.Name, ephemeralIDEPort, "")
+	}
+
+	fmt.Fprintf(o.Out, "🐞 forward the IDE port ⬇️ and open http://localhost:%d to start debugging\n\n", ephemeralIDEPort)
+	fmt.Fprintf(o.Out, "kubectl port-forward -n %s pod/%s %d\n", namespace, pod.Name, ephemeralIDEPort)
+	return nil
+}
+
+func ephemeralContainerStatus(pod *corev1.Pod, name string) *corev1.ContainerStatus {
+	for i := range pod.Status.EphemeralContainerStatuses {
+		if pod.Status.EphemeralContainerStatuses[i].Name == name {
+			return &pod.Status.EphemeralContainerStatuses[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func Test_ephemeralContainer(t *testing.T) {
+	tests := []struct {
+		name              string
+		o                 DebugIDEOptions
+		wantProjectSource string
+		wantRepository    string
+		wantRevision      string
+		wantErr           bool
+	}{
+		{
+			name:              "no git repository",
+			o:                 DebugIDEOptions{debugImage: defaultEphemeralImage, targetContainer: "outyet"},
+			wantProjectSource: "/projects",
+		},
+		{
+			name: "git repository",
+			o: DebugIDEOptions{
+				debugImage:      defaultEphemeralImage,
+				targetContainer: "outyet",
+				gitRepositories: []gitRepository{{remote: "https://github.com/l0rd/outyet.git", revision: "4f3e2a1"}},
+			},
+			wantProjectSource: "/projects/outyet",
+			wantRepository:    "https://github.com/l0rd/outyet.git",
+			wantRevision:      "4f3e2a1",
+		},
+		{
+			name: "invalid git repository",
+			o: DebugIDEOptions{
+				targetContainer: "outyet",
+				gitRepositories: []gitRepository{{remote: "outyet"}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ephemeralContainer(tt.o)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ephemeralContainer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !strings.HasPrefix(got.Name, ephemeralContainerNamePrefix) || got.Image != tt.o.debugImage {
+				t.Errorf("ephemeralContainer() = %s %s", got.Name, got.Image)
+			}
+			if got.TargetContainerName != tt.o.targetContainer {
+				t.Errorf("ephemeralContainer() target container = %q, want %q", got.TargetContainerName, tt.o.targetContainer)
+			}
+			if got.WorkingDir != ephemeralProjectsRoot {
+				t.Errorf("ephemeralContainer() working dir = %q, want %q", got.WorkingDir, ephemeralProjectsRoot)
+			}
+			env := map[string]string{}
+			for _, e := range got.Env {
+				env[e.Name] = e.Value
+			}
+			want := map[string]string{
+				cheCodeContributionContainerEnvName: cheCodeContributionContainerEnvValue,
+				"PROJECTS_ROOT":                     ephemeralProjectsRoot,
+				"PROJECT_SOURCE":                    tt.wantProjectSource,
+				"GIT_REPOSITORY":                    tt.wantRepository,
+				"GIT_REVISION":                      tt.wantRevision,
+			}
+			for k, v := range want {
+				if env[k] != v {
+					t.Errorf("ephemeralContainer() env %s = %q, want %q", k, env[k], v)
+				}
+			}
+			if len(got.Ports) > 0 || len(got.VolumeMounts) > 0 {
+				t.Errorf("ephemeralContainer() has ports or volume mounts: %v %v", got.Ports, got.VolumeMounts)
+			}
+			script := strings.Join(got.Command, " ")
+			for _, w := range []string{"command -v git", "git not found in the image", "che-code not found in the image"} {
+				if !strings.Contains(script, w) {
+					t.Errorf("ephemeralContainer() command doesn't contain %q:\n%s", w, script)
+				}
+			}
+			if got.ImagePullPolicy != corev1.PullIfNotPresent {
+				t.Errorf("ephemeralContainer() pull policy = %s", got.ImagePullPolicy)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// gitRepository is a repository cloned in the debugging container
+// and the revision (branch, tag or commit) to check out
+type gitRepository struct {
+	remote   string
+	revision string
+}
+
+// String returns the repository in the --git-repository format
+func (r gitRepository) String() string {
+	if r.revision == "" {
+		return r.remote
+	}
+	return r.remote + "#" + r.revision
+}
+
+// parseGitRepositories parses the --git-repository values, URLs optionally
+// followed by #<revision>. The revision of the repositories that don't
+// specify one is defaultRevision.
+func parseGitRepositories(values []string, defaultRevision string) ([]gitRepository, error) {
+	repos := make([]gitRepository, 0, len(values))
+	names := map[string]string{}
+	for _, v := range values {
+		remote, revision, _ := strings.Cut(v, "#")
+		if remote == "" {
+			return nil, fmt.Errorf("invalid git repository %q: the URL is empty", v)
+		}
+		if revision == "" {
+			revision = defaultRevision
+		}
+		name, err := projectName(remote)
+		if err != nil {
+			return nil, fmt.Errorf("invalid git repository %q: %v", v, err)
+		}
+		if other, ok := names[name]; ok {
+			return nil, fmt.Errorf("git repositories %s and %s would be cloned in the same folder %s", other, remote, name)
+		}
+		names[name] = remote
+		repos = append(repos, gitRepository{remote: remote, revision: revision})
+	}
+	return repos, nil
+}
+
+// gitRepositoriesString returns the repositories in the --git-repository
+// format, separated by commas
+func gitRepositoriesString(repos []gitRepository) string {
+	s := make([]string, 0, len(repos))
+	for _, r := range repos {
+		s = append(s, r.String())
+	}
+	return strings.Join(s, ",")
+}
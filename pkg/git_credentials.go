@@ -0,0 +1,416 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	dwv1alpha2 "github.com/devfile/api/v2/pkg/apis/workspaces/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The DevWorkspace Operator mounts the git credentials Secrets in all the
+// workspaces of the namespace, including the projects clone init container.
+// The ssh key is mounted in the CDE container of the session only, that
+// clones the git@ and ssh:// repositories checking the host keys.
+const (
+	gitCredentialLabel        = "controller.devfile.io/git-credential"
+	mountToDevWorkspaceLabel  = "controller.devfile.io/mount-to-devworkspace"
+	gitCredentialsKey         = "credentials"
+	gitCredentialsSuffix      = "-git-credentials"
+	gitSSHKeySuffix           = "-git-ssh-key"
+	gitSSHKeyVolumeName       = "debug-ide-git-ssh-key"
+	gitSSHKeyMountPath        = "/etc/debug-ide/git-ssh"
+	gitSSHPrivateKeyKey       = "ssh_key"
+	gitKnownHostsKey          = "known_hosts"
+	gitSSHCloneCommandID      = "clone-ssh-projects"
+	gitTokenUsername          = "oauth2"
+	defaultGitCredentialsPath = "~/.git-credentials"
+	defaultGitKnownHostsPath  = "~/.ssh/known_hosts"
+	// gitSSHCloneScript clones the git@ and ssh:// repositories with the
+	// ssh key of the session. It never fails as a failing postStart command
+	// would kill the container: the errors are logged in $CLONE_DIR/clone.log.
+	gitSSHCloneScript = `CLONE_DIR=/tmp/git-ssh
+mkdir -p "$CLONE_DIR" && chmod 700 "$CLONE_DIR"
+if ! command -v git > /dev/null; then
+  echo "git not found in the image" > "$CLONE_DIR/clone.log"
+  exit 0
+fi
+cp %[1]s/%[2]s "$CLONE_DIR/ssh_key" && chmod 600 "$CLONE_DIR/ssh_key"
+SSH_COMMAND="ssh -i $CLONE_DIR/ssh_key -o IdentitiesOnly=yes -o UserKnownHostsFile=%[1]s/%[3]s -o StrictHostKeyChecking=yes"
+clone() {
+  [ -d "$2" ] && return 0
+  git clone --config core.sshCommand="$SSH_COMMAND" "$1" "$2" && { [ -z "$3" ] || git -C "$2" checkout "$3"; }
+}
+`
+)
+
+func gitCredentialsSecretName(dwName string) string {
+	return dwName + gitCredentialsSuffix
+}
+
+func gitSSHKeySecretName(dwName string) string {
+	return dwName + gitSSHKeySuffix
+}
+
+// isSSHRemote returns true for the scp-like (git@github.com:l0rd/outyet.git)
+// and the ssh:// remotes
+func isSSHRemote(remote string) bool {
+	if strings.HasPrefix(remote, "ssh://") {
+		return true
+	}
+	return !strings.Contains(remote, "://") && strings.Contains(remote, "@") && strings.Contains(remote, ":")
+}
+
+func hasSSHRemote(repos []gitRepository) bool {
+	for _, r := range repos {
+		if isSSHRemote(r.remote) {
+			return true
+		}
+	}
+	return false
+}
+
+// httpsRemoteHosts returns the hosts of the repositories that are not cloned through ssh
+func httpsRemoteHosts(repos []gitRepository) []string {
+	hosts := map[string]bool{}
+	for _, r := range repos {
+		if isSSHRemote(r.remote) {
+			continue
+		}
+		if u, err := url.Parse(r.remote); err == nil && u.Host != "" {
+			hosts[u.Scheme+"://"+u.Host] = true
+		}
+	}
+	list := make([]string, 0, len(hosts))
+	for h := range hosts {
+		list = append(list, h)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// tokenCredentials returns the git-credentials lines that use token for the
+// hosts of the https repositories
+func tokenCredentials(repos []gitRepository, token string) (string, error) {
+	hosts := httpsRemoteHosts(repos)
+	if len(hosts) == 0 {
+		return "", fmt.Errorf("--git-token requires an https --git-repository")
+	}
+	lines := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		u, err := url.Parse(h)
+		if err != nil {
+			return "", err
+		}
+		u.User = url.UserPassword(gitTokenUsername, token)
+		lines = append(lines, u.String())
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// matchingCredentials returns the lines of a git-credentials file that
+// match the hosts of the https repositories
+func matchingCredentials(content string, repos []gitRepository) string {
+	hosts := map[string]bool{}
+	for _, h := range httpsRemoteHosts(repos) {
+		hosts[h] = true
+	}
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		u, err := url.Parse(line)
+		if line == "" || err != nil || u.User == nil {
+			continue
+		}
+		if hosts[u.Scheme+"://"+u.Host] {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func expandHome(p string) (string, error) {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error getting the home directory: %v", err)
+	}
+	return filepath.Join(home, strings.TrimPrefix(p, "~")), nil
+}
+
+// readGitCredentials returns the entries of the git-credentials file p
+// that match the https repositories
+func readGitCredentials(p string, repos []gitRepository) (string, error) {
+	p, err := expandHome(p)
+	if err != nil {
+		return "", err
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return "", fmt.Errorf("error reading git credentials %s: %v", p, err)
+	}
+	credentials := matchingCredentials(string(b), repos)
+	if credentials == "" {
+		return "", fmt.Errorf("no entry of %s matches the https git repositories", p)
+	}
+	return credentials, nil
+}
+
+// gitCredentialsSecret returns the git credentials Secret in the
+// DevWorkspace Operator format
+func gitCredentialsSecret(name, credentials string) *corev1.Secret {
+	labels := sessionLabels()
+	labels[gitCredentialLabel] = "true"
+	return &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Secret",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+		StringData: map[string]string{
+			gitCredentialsKey: credentials,
+		},
+	}
+}
+
+// gitSSHKeySecret returns the Secret with the ssh deploy key and
+// the known_hosts entries of the hosts of the ssh repositories
+func gitSSHKeySecret(name string, privateKey []byte, knownHosts string) *corev1.Secret {
+	return &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Secret",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: sessionLabels(),
+		},
+		Data: map[string][]byte{
+			gitSSHPrivateKeyKey: privateKey,
+			gitKnownHostsKey:    []byte(knownHosts),
+		},
+	}
+}
+
+// gitSSHKeyVolume is the volume of the Secret with the ssh
+// deploy key, mounted in the CDE container of the session only
+func gitSSHKeyVolume(dwName string) sessionVolume {
+	return sessionVolume{
+		volume: corev1.Volume{
+			Name: gitSSHKeyVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: gitSSHKeySecretName(dwName)},
+			},
+		},
+		mount: corev1.VolumeMount{Name: gitSSHKeyVolumeName, MountPath: gitSSHKeyMountPath, ReadOnly: true},
+	}
+}
+
+// readGitSSHKey reads the private key p
+func readGitSSHKey(p string) ([]byte, error) {
+	p, err := expandHome(p)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := os.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("error reading git ssh key %s: %v", p, err)
+	}
+	return privateKey, nil
+}
+
+// sshRemoteHost returns the host of a git@ or ssh:// remote
+// as written in known_hosts, [host]:port for the other ports than 22
+func sshRemoteHost(remote string) (string, error) {
+	host, port := "", ""
+	if strings.HasPrefix(remote, "ssh://") {
+		u, err := url.Parse(remote)
+		if err != nil {
+			return "", fmt.Errorf("invalid git repository %s: %v", remote, err)
+		}
+		host, port = u.Hostname(), u.Port()
+	} else {
+		host = remote[strings.Index(remote, "@")+1 : strings.Index(remote, ":")]
+	}
+	if host == "" {
+		return "", fmt.Errorf("invalid git repository %s: no host", remote)
+	}
+	if port == "" || port == "22" {
+		return host, nil
+	}
+	return "[" + host + "]:" + port, nil
+}
+
+// knownHostMatches returns true if a host pattern of a known_hosts line,
+// hashed (|1|salt|hash) or not, matches host
+func knownHostMatches(pattern, host string) bool {
+	if strings.HasPrefix(pattern, "|1|") {
+		parts := strings.Split(pattern[len("|1|"):], "|")
+		if len(parts) != 2 {
+			return false
+		}
+		salt, err := base64.StdEncoding.DecodeString(parts[0])
+		if err != nil {
+			return false
+		}
+		hash, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha1.New, salt)
+		mac.Write([]byte(host))
+		return hmac.Equal(mac.Sum(nil), hash)
+	}
+	if strings.ContainsAny(pattern, "*?") {
+		matched, err := path.Match(pattern, host)
+		return err == nil && matched
+	}
+	return pattern == host
+}
+
+// matchingKnownHosts returns the lines of a known_hosts file that match
+// the hosts of the ssh repositories. Every host must have one.
+func matchingKnownHosts(content string, repos []gitRepository) (string, error) {
+	var lines []string
+	for _, r := range repos {
+		if !isSSHRemote(r.remote) {
+			continue
+		}
+		host, err := sshRemoteHost(r.remote)
+		if err != nil {
+			return "", err
+		}
+		found := false
+		scanner := bufio.NewScanner(strings.NewReader(content))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			fields := strings.Fields(line)
+			if len(fields) > 0 && strings.HasPrefix(fields[0], "@") {
+				fields = fields[1:]
+			}
+			if len(fields) < 3 || strings.HasPrefix(line, "#") {
+				continue
+			}
+			for _, pattern := range strings.Split(fields[0], ",") {
+				if knownHostMatches(pattern, host) {
+					found = true
+					lines = append(lines, line)
+					break
+				}
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("no known_hosts entry of %s", host)
+		}
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// readGitKnownHosts returns the entries of the known_hosts file p
+// that match the hosts of the ssh repositories
+func readGitKnownHosts(p string, repos []gitRepository) (string, error) {
+	p, err := expandHome(p)
+	if err != nil {
+		return "", err
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return "", fmt.Errorf("error reading known hosts %s: %v", p, err)
+	}
+	knownHosts, err := matchingKnownHosts(string(b), repos)
+	if err != nil {
+		return "", fmt.Errorf("%v in %s: add it with ssh-keyscan or use --git-known-hosts", err, p)
+	}
+	return knownHosts, nil
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// gitSSHCloneCommand is the postStart command that clones the git@
+// and ssh:// repositories in the CDE container with the ssh key
+func gitSSHCloneCommand(repos []gitRepository) (dwv1alpha2.Command, error) {
+	script := fmt.Sprintf(gitSSHCloneScript, gitSSHKeyMountPath, gitSSHPrivateKeyKey, gitKnownHostsKey)
+	for _, r := range repos {
+		if !isSSHRemote(r.remote) {
+			continue
+		}
+		name, err := projectName(r.remote)
+		if err != nil {
+			return dwv1alpha2.Command{}, err
+		}
+		script += fmt.Sprintf("clone %s %s %s >> \"$CLONE_DIR/clone.log\" 2>&1 || true\n",
+			shellQuote(r.remote), shellQuote(path.Join(projectsRoot, name)), shellQuote(r.revision))
+	}
+	return dwv1alpha2.Command{
+		Id: gitSSHCloneCommandID,
+		CommandUnion: dwv1alpha2.CommandUnion{
+			Exec: &dwv1alpha2.ExecCommand{
+				Component:   defaultDevContainerName,
+				CommandLine: script,
+			},
+		},
+	}, nil
+}
+
+// gitSecrets returns the git credentials Secrets to create for a session
+func (o *DebugIDEOptions) gitSecrets() []*corev1.Secret {
+	var secrets []*corev1.Secret
+	if o.gitCredentials != "" {
+		secrets = append(secrets, gitCredentialsSecret(gitCredentialsSecretName(o.devWorkspaceName()), o.gitCredentials))
+	}
+	if len(o.gitSSHPrivateKey) > 0 {
+		secrets = append(secrets, gitSSHKeySecret(gitSSHKeySecretName(o.devWorkspaceName()), o.gitSSHPrivateKey, o.gitKnownHosts))
+	}
+	return secrets
+}
+
+// warnGitSecrets tells that the DevWorkspace Operator mounts the session
+// git credentials in all the DevWorkspaces of the namespace
+func (o *DebugIDEOptions) warnGitSecrets() {
+	if o.gitCredentials != "" {
+		fmt.Fprintf(o.ErrOut, "⚠️ the git credentials will be available to all the DevWorkspaces of namespace %s until the session is deleted\n", o.clients.namespace)
+	}
+}
+
+// checkGitCredentialsSecret verifies that an existing Secret is
+// a git credentials Secret of the DevWorkspace Operator
+func checkGitCredentialsSecret(ctx context.Context, clients *kubeClients, name string) error {
+	s, err := clients.clientset.CoreV1().Secrets(clients.namespace).Get(ctx, name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return fmt.Errorf("git credentials Secret %s not found in namespace %s", name, clients.namespace)
+	}
+	if err != nil {
+		return fmt.Errorf("error getting git credentials Secret %s: %v", name, err)
+	}
+	if s.Labels[gitCredentialLabel] != "true" {
+		return fmt.Errorf("the Secret %s is not labelled %s=true, the DevWorkspace Operator ignores it", name, gitCredentialLabel)
+	}
+	if _, ok := s.Data[gitCredentialsKey]; !ok {
+		return fmt.Errorf("the Secret %s has no %q key", name, gitCredentialsKey)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseGitRepositories(t *testing.T) {
+	tests := []struct {
+		name            string
+		values          []string
+		defaultRevision string
+		want            []gitRepository
+		wantErr         bool
+	}{
+		{
+			name: "no repository",
+			want: []gitRepository{},
+		},
+		{
+			name:   "repositories with and without revision",
+			values: []string{"https://github.com/l0rd/outyet.git#v1.2.0", "git@github.com:l0rd/outyet-tools.git"},
+			want: []gitRepository{
+				{remote: "https://github.com/l0rd/outyet.git", revision: "v1.2.0"},
+				{remote: "git@github.com:l0rd/outyet-tools.git"},
+			},
+		},
+		{
+			name:            "default revision",
+			values:          []string{"https://github.com/l0rd/outyet#main", "https://github.com/l0rd/outyet-tools"},
+			defaultRevision: "4f3e2a1",
+			want: []gitRepository{
+				{remote: "https://github.com/l0rd/outyet", revision: "main"},
+				{remote: "https://github.com/l0rd/outyet-tools", revision: "4f3e2a1"},
+			},
+		},
+		{
+			name:    "empty URL",
+			values:  []string{"#main"},
+			wantErr: true,
+		},
+		{
+			name:    "same project name",
+			values:  []string{"https://github.com/l0rd/outyet", "https://gitlab.com/l0rd/outyet.git"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseGitRepositories(tt.values, tt.defaultRevision)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseGitRepositories() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseGitRepositories() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	dwv1alpha2 "github.com/devfile/api/v2/pkg/apis/workspaces/v1alpha2"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	defaultPluginRegistryURL       = "https://eclipse-che.github.io/che-plugin-registry/main/v3"
+	ideTemplateKind                = "DevWorkspaceTemplate"
+	ideTemplateSuffix              = "-ide"
+	ideRuntimeComponentSuffix      = "-runtime-description"
+	defaultIDEContributionName     = "ide"
+	pluginRegistryDevfileName      = "devfile.yaml"
+	pluginRegistryPluginsDirectory = "plugins"
+)
+
+// A registry short name is <publisher>/<name>/<version>
+// e.g. che-incubator/che-idea/latest
+var pluginIDRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]*/[a-z0-9][a-z0-9.-]*/[a-z0-9][a-z0-9.-]*$`)
+
+// ideDefinition is the IDE that gets contributed to the DevWorkspace
+type ideDefinition struct {
+	// name of the IDE (e.g. che-code) used for the contribution name
+	name string
+	// importReference is the location of the IDE devfile. For local
+	// devfiles it's ignored and the template is referenced instead.
+	importReference dwv1alpha2.ImportReference
+	// runtimeComponent is the name of the IDE component that runs the IDE
+	// and where the env overrides are applied
+	runtimeComponent string
+	env              []dwv1alpha2.EnvVarPluginOverride
+	// template holds the content of a local IDE devfile. It's created in
+	// the cluster as a DevWorkspaceTemplate that the contribution refers to.
+	template *dwv1alpha2.DevWorkspaceTemplateSpec
+}
+
+// resolveIDE parses the --ide reference that can be an URL to a devfile,
+// the path of a local devfile or a plugin registry short name
+func resolveIDE(reference, registry, runtimeComponent string, envOverrides []string) (ideDefinition, error) {
+	ide := ideDefinition{}
+
+	switch {
+	case isURL(reference):
+		ide.importReference.Uri = reference
+		ide.name = ideNameFromURI(reference)
+	case fileExists(reference):
+		t, err := readIDEDevfile(reference)
+		if err != nil {
+			return ideDefinition{}, err
+		}
+		ide.template = t
+		ide.name = strings.TrimSuffix(path.Base(reference), path.Ext(reference))
+		if ide.name == "devfile" {
+			ide.name = path.Base(path.Dir(reference))
+		}
+	case pluginIDRegexp.MatchString(reference):
+		ide.importReference.Uri = strings.Join(
+			[]string{strings.TrimSuffix(registry, "/"), pluginRegistryPluginsDirectory, reference, pluginRegistryDevfileName}, "/")
+		ide.name = strings.Split(reference, "/")[1]
+	default:
+		return ideDefinition{}, fmt.Errorf("invalid IDE %q: it's not an URL, an existing file nor a plugin registry id (<publisher>/<name>/<version>)", reference)
+	}
+
+	if ide.name == "" {
+		ide.name = defaultIDEContributionName
+	}
+	if errs := validation.IsDNS1123Label(ide.name); len(errs) > 0 {
+		return ideDefinition{}, fmt.Errorf("invalid IDE name %q, taken from %s: %s", ide.name, reference, strings.Join(errs, ", "))
+	}
+
+	// The components of a local devfile are known, the ones of a remote
+	// devfile are not: the plugin registry names the runtime component
+	// <name>-runtime-description
+	ide.runtimeComponent = runtimeComponent
+	switch {
+	case ide.template != nil && runtimeComponent != "":
+		if !hasContainerComponent(ide.template, runtimeComponent) {
+			return ideDefinition{}, fmt.Errorf("invalid --ide-component %q: the IDE devfile %s has no container component with this name", runtimeComponent, reference)
+		}
+	case ide.template != nil:
+		ide.runtimeComponent = templateRuntimeComponent(ide.template, ide.name)
+	case runtimeComponent == "" && ide.name != defaultIDEContributionName:
+		ide.runtimeComponent = ide.name + ideRuntimeComponentSuffix
+	}
+
+	// che-code listens on localhost by default
+	if ide.name == cheCodeContributionName {
+		ide.env = append(ide.env, dwv1alpha2.EnvVarPluginOverride{
+			Name:  cheCodeContributionContainerEnvName,
+			Value: cheCodeContributionContainerEnvValue,
+		})
+	}
+
+	for _, e := range envOverrides {
+		name, value, found := strings.Cut(e, "=")
+		if !found || name == "" {
+			return ideDefinition{}, fmt.Errorf("invalid IDE env %q, expected NAME=VALUE", e)
+		}
+		ide.env = setEnvOverride(ide.env, name, value)
+	}
+
+	if len(ide.env) > 0 && ide.runtimeComponent == "" {
+		return ideDefinition{}, fmt.Errorf("cannot override the IDE env: the IDE runtime component name is unknown, use --ide-component")
+	}
+
+	return ide, nil
+}
+
+func hasContainerComponent(t *dwv1alpha2.DevWorkspaceTemplateSpec, name string) bool {
+	for _, c := range t.Components {
+		if c.Name == name && c.Container != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// templateRuntimeComponent returns the container component of a local IDE
+// devfile that runs the IDE: the only one, or the one named as in the
+// plugin registry. It's empty if it cannot be told.
+func templateRuntimeComponent(t *dwv1alpha2.DevWorkspaceTemplateSpec, name string) string {
+	var containers []string
+	for _, c := range t.Components {
+		if c.Container != nil {
+			containers = append(containers, c.Name)
+		}
+	}
+	if len(containers) == 1 {
+		return containers[0]
+	}
+	if hasContainerComponent(t, name+ideRuntimeComponentSuffix) {
+		return name + ideRuntimeComponentSuffix
+	}
+	return ""
+}
+
+func setEnvOverride(env []dwv1alpha2.EnvVarPluginOverride, name, value string) []dwv1alpha2.EnvVarPluginOverride {
+	for i := range env {
+		if env[i].Name == name {
+			env[i].Value = value
+			return env
+		}
+	}
+	return append(env, dwv1alpha2.EnvVarPluginOverride{Name: name, Value: value})
+}
+
+func isURL(reference string) bool {
+	u, err := url.Parse(reference)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func fileExists(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && !info.IsDir()
+}
+
+// ideNameFromURI returns the IDE name of plugin registry URIs such as
+// <registry>/plugins/che-incubator/che-code/latest/devfile.yaml
+func ideNameFromURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return ""
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i, s := range segments {
+		if s == pluginRegistryPluginsDirectory && i+2 < len(segments) {
+			return segments[i+2]
+		}
+	}
+	return ""
+}
+
+func readIDEDevfile(p string) (*dwv1alpha2.DevWorkspaceTemplateSpec, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("error reading IDE devfile %s: %v", p, err)
+	}
+	t := &dwv1alpha2.DevWorkspaceTemplateSpec{}
+	if err := yaml.Unmarshal(b, t); err != nil {
+		return nil, fmt.Errorf("error parsing IDE devfile %s: %v", p, err)
+	}
+	if len(t.Components) == 0 {
+		return nil, fmt.Errorf("invalid IDE devfile %s: no components found", p)
+	}
+	return t, nil
+}
+
+func ideTemplateName(dwName string) string {
+	return dwName + ideTemplateSuffix
+}
+
+// ideTemplate returns the DevWorkspaceTemplate to create when the IDE is
+// defined in a local devfile
+func ideTemplate(ide ideDefinition, dwName string) *dwv1alpha2.DevWorkspaceTemplate {
+	if ide.template == nil {
+		return nil
+	}
+	return &dwv1alpha2.DevWorkspaceTemplate{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       ideTemplateKind,
+			APIVersion: apiVersion,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   ideTemplateName(dwName),
+			Labels: sessionLabels(),
+		},
+		Spec: *ide.template.DeepCopy(),
+	}
+}
+
+// applyIDETemplate creates, or updates if it already exists, the
+// DevWorkspaceTemplate of a local IDE devfile. The DevWorkspaceTemplates
+// that weren't created by kubectl debug-ide are never replaced.
+func applyIDETemplate(
+	ctx context.Context,
+	dynClient dynamic.Interface,
+	rm meta.RESTMapper,
+	namespace string,
+	t *dwv1alpha2.DevWorkspaceTemplate,
+) error {
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(t)
+	if err != nil {
+		return fmt.Errorf("found error while converting resource to unstructured err - %v", err)
+	}
+	u := &unstructured.Unstructured{Object: obj}
+
+	gvk := t.GroupVersionKind()
+	mapping, err := rm.RESTMapping(schema.GroupKind{Group: gvk.Group, Kind: gvk.Kind}, gvk.Version)
+	if err != nil {
+		return fmt.Errorf("RESTMapping error: %v", err)
+	}
+
+	client := dynClient.Resource(mapping.Resource).Namespace(namespace)
+	existing, err := client.Get(ctx, t.Name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		_, err = client.Create(ctx, u, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("error creating DevWorkspaceTemplate %s: %v", t.Name, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting DevWorkspaceTemplate %s: %v", t.Name, err)
+	}
+	if existing.GetLabels()[managedByLabel] != managedByValue {
+		return fmt.Errorf("the DevWorkspaceTemplate %s wasn't created by kubectl debug-ide and cannot be replaced", t.Name)
+	}
+
+	u.SetResourceVersion(existing.GetResourceVersion())
+	_, err = client.Update(ctx, u, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("error updating DevWorkspaceTemplate %s: %v", t.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package pkg
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	dwv1alpha2 "github.com/devfile/api/v2/pkg/apis/workspaces/v1alpha2"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func Test_resolveIDE(t *testing.T) {
+	dir := t.TempDir()
+	writeDevfile := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	singleComponent := `schemaVersion: 2.2.0
+metadata:
+  name: my-ide
+components:
+  - name: my-ide-runtime
+    container:
+      image: quay.io/example/my-ide:latest
+`
+	localDevfile := writeDevfile("my-ide.yaml", singleComponent)
+	invalidName := writeDevfile("My IDE.yaml", singleComponent)
+	dirDevfile := writeDevfile(filepath.Join("other-ide", "devfile.yaml"), singleComponent)
+	multiComponents := writeDevfile("multi.yaml", `schemaVersion: 2.2.0
+components:
+  - name: editor
+    container:
+      image: quay.io/example/editor:latest
+  - name: multi-runtime-description
+    container:
+      image: quay.io/example/multi:latest
+  - name: editor-data
+    volume: {}
+`)
+	noRuntime := writeDevfile("no-runtime.yaml", `schemaVersion: 2.2.0
+components:
+  - name: editor
+    container:
+      image: quay.io/example/editor:latest
+  - name: server
+    container:
+      image: quay.io/example/server:latest
+`)
+
+	codeHost := dwv1alpha2.EnvVarPluginOverride{Name: "CODE_HOST", Value: "0.0.0.0"}
+
+	type args struct {
+		reference string
+		component string
+		env       []string
+	}
+	tests := []struct {
+		name          string
+		args          args
+		wantName      string
+		wantURI       string
+		wantComponent string
+		wantEnv       []dwv1alpha2.EnvVarPluginOverride
+		wantTemplate  bool
+		wantErr       bool
+	}{
+		{
+			name:          "default che-code URI",
+			args:          args{reference: defaultIdeReference},
+			wantName:      "che-code",
+			wantURI:       defaultIdeReference,
+			wantComponent: "che-code-runtime-description",
+			wantEnv:       []dwv1alpha2.EnvVarPluginOverride{codeHost},
+		},
+		{
+			name:          "registry id",
+			args:          args{reference: "che-incubator/che-idea/latest"},
+			wantName:      "che-idea",
+			wantURI:       defaultPluginRegistryURL + "/plugins/che-incubator/che-idea/latest/devfile.yaml",
+			wantComponent: "che-idea-runtime-description",
+		},
+		{
+			name:          "che-code env override",
+			args:          args{reference: defaultIdeReference, env: []string{"CODE_HOST=127.0.0.1", "FOO=bar"}},
+			wantName:      "che-code",
+			wantURI:       defaultIdeReference,
+			wantComponent: "che-code-runtime-description",
+			wantEnv: []dwv1alpha2.EnvVarPluginOverride{
+				{Name: "CODE_HOST", Value: "127.0.0.1"},
+				{Name: "FOO", Value: "bar"},
+			},
+		},
+		{
+			name:          "local devfile",
+			args:          args{reference: localDevfile, component: "my-ide-runtime", env: []string{"FOO=bar"}},
+			wantName:      "my-ide",
+			wantComponent: "my-ide-runtime",
+			wantEnv:       []dwv1alpha2.EnvVarPluginOverride{{Name: "FOO", Value: "bar"}},
+			wantTemplate:  true,
+		},
+		{
+			name:          "local devfile runtime component",
+			args:          args{reference: localDevfile, env: []string{"FOO=bar"}},
+			wantName:      "my-ide",
+			wantComponent: "my-ide-runtime",
+			wantEnv:       []dwv1alpha2.EnvVarPluginOverride{{Name: "FOO", Value: "bar"}},
+			wantTemplate:  true,
+		},
+		{
+			name:          "local devfile named after its directory",
+			args:          args{reference: dirDevfile},
+			wantName:      "other-ide",
+			wantComponent: "my-ide-runtime",
+			wantTemplate:  true,
+		},
+		{
+			name:          "local devfile with the registry runtime component",
+			args:          args{reference: multiComponents},
+			wantName:      "multi",
+			wantComponent: "multi-runtime-description",
+			wantTemplate:  true,
+		},
+		{
+			name:    "local devfile without a runtime component",
+			args:    args{reference: noRuntime, env: []string{"FOO=bar"}},
+			wantErr: true,
+		},
+		{
+			name:    "--ide-component not in the local devfile",
+			args:    args{reference: localDevfile, component: "editor", env: []string{"FOO=bar"}},
+			wantErr: true,
+		},
+		{
+			name:    "local devfile with an invalid name",
+			args:    args{reference: invalidName},
+			wantErr: true,
+		},
+		{
+			name:    "env override with unknown component",
+			args:    args{reference: "https://example.com/ide.yaml", env: []string{"FOO=bar"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid env override",
+			args:    args{reference: defaultIdeReference, env: []string{"FOO"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid reference",
+			args:    args{reference: "not-an-ide"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveIDE(tt.args.reference, defaultPluginRegistryURL, tt.args.component, tt.args.env)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("resolveIDE() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.name != tt.wantName {
+				t.Errorf("resolveIDE() name = %v, want %v", got.name, tt.wantName)
+			}
+			if got.importReference.Uri != tt.wantURI {
+				t.Errorf("resolveIDE() uri = %v, want %v", got.importReference.Uri, tt.wantURI)
+			}
+			if got.runtimeComponent != tt.wantComponent {
+				t.Errorf("resolveIDE() component = %v, want %v", got.runtimeComponent, tt.wantComponent)
+			}
+			if !reflect.DeepEqual(got.env, tt.wantEnv) {
+				t.Errorf("resolveIDE() env = %v, want %v", got.env, tt.wantEnv)
+			}
+			if (got.template != nil) != tt.wantTemplate {
+				t.Errorf("resolveIDE() template = %v, wantTemplate %v", got.template, tt.wantTemplate)
+			}
+		})
+	}
+}
+
+func Test_applyIDETemplate(t *testing.T) {
+	ide := ideDefinition{template: &dwv1alpha2.DevWorkspaceTemplateSpec{}}
+	managed := ideTemplate(ide, "managed-dw")
+	managed.Namespace = testNamespace
+	unmanaged := ideTemplate(ide, "unmanaged-dw")
+	unmanaged.Namespace = testNamespace
+	unmanaged.Labels = nil
+
+	c := newFakeCluster(t, true)
+	ctx := context.Background()
+	for _, tmpl := range []*dwv1alpha2.DevWorkspaceTemplate{managed, unmanaged} {
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.dynClient.Tracker().Add(&unstructured.Unstructured{Object: obj}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		dwName  string
+		wantErr bool
+	}{
+		{name: "new", dwName: "new-dw"},
+		{name: "managed", dwName: "managed-dw"},
+		{name: "unmanaged", dwName: "unmanaged-dw", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := applyIDETemplate(ctx, c.dynClient, c.mapper, testNamespace, ideTemplate(ide, tt.dwName))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("applyIDETemplate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/client-go/dynamic"
+)
+
+type lifecycleAction string
+
+const (
+	actionDelete lifecycleAction = "delete"
+	actionStop   lifecycleAction = "stop"
+	actionResume lifecycleAction = "resume"
+
+	defaultDeleteTimeout = 5 * time.Minute
+)
+
+var lifecycleExamples = map[lifecycleAction]string{
+	actionDelete: `
+	# Delete the debugging session <session-name> and wait for its resources to be cleaned up
+	%[1]s debug-ide delete <session-name>
+
+	# Delete the debugging sessions of the Pod <pod-name>
+	%[1]s debug-ide delete --pod <pod-name>
+
+	# Delete all the debugging sessions in the current namespace
+	%[1]s debug-ide delete --all`,
+	actionStop: `
+	# Stop the debugging session <session-name>, keeping its definition to resume it later
+	%[1]s debug-ide stop <session-name>
+
+	# Stop all the debugging sessions in the current namespace
+	%[1]s debug-ide stop --all`,
+	actionResume: `
+	# Resume the stopped debugging session <session-name>
+	%[1]s debug-ide resume <session-name>`,
+}
+
+var lifecycleShort = map[lifecycleAction]string{
+	actionDelete: "Delete debugging sessions and wait for their resources to be cleaned up.",
+	actionStop:   "Stop debugging sessions, the DevWorkspace is kept and can be resumed.",
+	actionResume: "Resume stopped debugging sessions.",
+}
+
+var lifecyclePastTense = map[lifecycleAction]string{
+	actionDelete: "deleted",
+	actionStop:   "stopped",
+	actionResume: "resumed",
+}
+
+// LifecycleOptions provides information required to delete,
+// stop or resume debugging sessions
+type LifecycleOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+
+	action    lifecycleAction
+	names     []string
+	all       bool
+	targetPod string
+	wait      bool
+	timeout   time.Duration
+	clients   *kubeClients
+
+	genericiooptions.IOStreams
+}
+
+// NewLifecycleOptions provides an instance of LifecycleOptions with default values
+func NewLifecycleOptions(
+	action lifecycleAction,
+	configFlags *genericclioptions.ConfigFlags,
+	streams genericiooptions.IOStreams,
+) *LifecycleOptions {
+	return &LifecycleOptions{
+		configFlags: configFlags,
+		action:      action,
+		wait:        true,
+		timeout:     defaultDeleteTimeout,
+
+		IOStreams: streams,
+	}
+}
+
+// NewCmdDelete provides a cobra command deleting debugging sessions
+func NewCmdDelete(configFlags *genericclioptions.ConfigFlags, streams genericiooptions.IOStreams) *cobra.Command {
+	return newCmdLifecycle(actionDelete, configFlags, streams)
+}
+
+// NewCmdStop provides a cobra command stopping debugging sessions
+func NewCmdStop(configFlags *genericclioptions.ConfigFlags, streams genericiooptions.IOStreams) *cobra.Command {
+	return newCmdLifecycle(actionStop, configFlags, streams)
+}
+
+// NewCmdResume provides a cobra command resuming debugging sessions
+func NewCmdResume(configFlags *genericclioptions.ConfigFlags, streams genericiooptions.IOStreams) *cobra.Command {
+	return newCmdLifecycle(actionResume, configFlags, streams)
+}
+
+func newCmdLifecycle(
+	action lifecycleAction,
+	configFlags *genericclioptions.ConfigFlags,
+	streams genericiooptions.IOStreams,
+) *cobra.Command {
+	o := NewLifecycleOptions(action, configFlags, streams)
+
+	cmd := &cobra.Command{
+		Use:          fmt.Sprintf("%s ([NAME...] | --pod POD | --all) [flags]", action),
+		Short:        lifecycleShort[action],
+		Example:      fmt.Sprintf(lifecycleExamples[action], "kubectl"),
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			if err := o.Run(c.Context()); err != nil {
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&o.all, "all", o.all, fmt.Sprintf("If true, %s all the debugging sessions in the namespace", action))
+	cmd.Flags().StringVar(&o.targetPod, "pod", o.targetPod, fmt.Sprintf("If set, %s the debugging sessions of this target Pod", action))
+	if action == actionDelete {
+		cmd.Flags().BoolVar(&o.wait, "wait", o.wait, "If true, wait for the finalizers to complete before returning")
+		cmd.Flags().DurationVar(&o.timeout, "timeout", o.timeout, "The length of time to wait for the deletion, zero means wait forever")
+	}
+
+	return cmd
+}
+
+// Complete sets all information required to find the debugging sessions
+func (o *LifecycleOptions) Complete(args []string) error {
+	o.names = args
+
+	var err error
+	o.clients, err = newKubeClients(o.configFlags)
+	return err
+}
+
+// Validate ensures that exactly one way to select the sessions is used
+func (o *LifecycleOptions) Validate() error {
+	selectors := 0
+	if len(o.names) > 0 {
+		selectors++
+	}
+	if o.all {
+		selectors++
+	}
+	if len(o.targetPod) > 0 {
+		selectors++
+	}
+	if selectors == 0 {
+		return fmt.Errorf("specify the names of the debugging sessions, --pod or --all")
+	}
+	if selectors > 1 {
+		return fmt.Errorf("session names, --pod and --all cannot be used together")
+	}
+	return nil
+}
+
+// Run applies the action to the selected debugging sessions
+func (o *LifecycleOptions) Run(ctx context.Context) error {
+	list, err := listAllSessions(ctx, o.clients, o.clients.namespace)
+	if err != nil {
+		return err
+	}
+
+	selected, err := o.selectSessions(list.Items)
+	if err != nil {
+		return err
+	}
+	if len(selected) == 0 {
+		fmt.Fprintf(o.ErrOut, "No debugging sessions found in %s namespace.\n", o.clients.namespace)
+		return nil
+	}
+
+	for _, s := range selected {
+		if s.backend == backendNative && o.action != actionDelete {
+			return fmt.Errorf("the debugging session %s is a plain Pod (native backend) and cannot be %s, delete it instead",
+				s.name, lifecyclePastTense[o.action])
+		}
+		switch o.action {
+		case actionDelete:
+			err = o.deleteSession(ctx, s)
+		case actionStop:
+			err = o.patchStarted(ctx, s.name, false)
+		case actionResume:
+			err = o.patchStarted(ctx, s.name, true)
+		}
+		if err != nil {
+			return err
+		}
+		resource := "devworkspace.workspace.devfile.io"
+		if s.backend == backendNative {
+			resource = "pod"
+		}
+		fmt.Fprintf(o.Out, "%s/%s %s\n", resource, s.name, lifecyclePastTense[o.action])
+	}
+	return nil
+}
+
+func (o *LifecycleOptions) patchStarted(ctx context.Context, name string, started bool) error {
+	gvr, err := devWorkspaceResource(o.clients.mapper)
+	if err != nil {
+		return err
+	}
+	return patchStarted(ctx, o.clients.dynClient.Resource(gvr).Namespace(o.clients.namespace), name, started)
+}
+
+func (o *LifecycleOptions) selectSessions(items []unstructured.Unstructured) ([]session, error) {
+	sessions := make(map[string]session, len(items))
+	for _, item := range items {
+		s := sessionFromUnstructured(item)
+		sessions[s.name] = s
+	}
+
+	var selected []session
+	switch {
+	case o.all:
+		for _, item := range items {
+			selected = append(selected, sessions[item.GetName()])
+		}
+	case len(o.targetPod) > 0:
+		for _, item := range items {
+			if s := sessions[item.GetName()]; s.targetPod == o.targetPod {
+				selected = append(selected, s)
+			}
+		}
+	default:
+		for _, name := range o.names {
+			s, ok := sessions[name]
+			if !ok {
+				return nil, fmt.Errorf("debugging session %s not found in namespace %s", name, o.clients.namespace)
+			}
+			selected = append(selected, s)
+		}
+	}
+	return selected, nil
+}
+
+func patchStarted(ctx context.Context, client dynamic.ResourceInterface, name string, started bool) error {
+	patch := []byte(fmt.Sprintf(`{"spec":{"started":%t}}`, started))
+	_, err := client.Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("error patching DevWorkspace %s: %v", name, err)
+	}
+	return nil
+}
+
+// deleteSession deletes the DevWorkspace, or the Pod of the native backend, of a session,
+// the DevWorkspaceTemplate of its IDE, its ssh and git credentials Secrets, the copies of
+// the ConfigMaps and Secrets of the target Pod and its ssh config, if any, and waits for the DevWorkspace finalizers to complete
+func (o *LifecycleOptions) deleteSession(ctx context.Context, s session) error {
+	if s.backend == backendNative {
+		client := o.clients.dynClient.Resource(podsResource).Namespace(o.clients.namespace)
+		if err := deleteAndWait(ctx, client, "Pod", s.name, o.wait, o.timeout); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	gvr, err := devWorkspaceResource(o.clients.mapper)
+	if err != nil {
+		return err
+	}
+	client := o.clients.dynClient.Resource(gvr).Namespace(o.clients.namespace)
+	if err := deleteAndWait(ctx, client, kind, s.name, o.wait, o.timeout); err != nil {
+		return err
+	}
+
+	templateGVR, err := devfileResource(o.clients.mapper, ideTemplateKind)
+	if err != nil {
+		return err
+	}
+	err = o.clients.dynClient.Resource(templateGVR).Namespace(o.clients.namespace).Delete(
+		ctx,
+		ideTemplateName(s.name),
+		metav1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("error deleting DevWorkspaceTemplate %s: %v", ideTemplateName(s.name), err)
+	}
+
+	for _, secret := range []string{sshSecretName(s.name), gitCredentialsSecretName(s.name), gitSSHKeySecretName(s.name)} {
+		err = o.clients.clientset.CoreV1().Secrets(o.clients.namespace).Delete(
+			ctx,
+			secret,
+			metav1.DeleteOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("error deleting Secret %s: %v", secret, err)
+		}
+	}
+	if err := deleteConfigCopies(ctx, o.clients, s.name); err != nil {
+		return err
+	}
+	if err := removeSSHConfig(o.clients.namespace, s.name); err != nil {
+		fmt.Fprintf(o.ErrOut, "warning: cannot remove the ssh config of %s: %v\n", s.name, err)
+	}
+	return nil
+}
+
+// deleteAndWait deletes the object name of type kind and waits for it to be gone
+func deleteAndWait(ctx context.Context, client dynamic.ResourceInterface, kind, name string, waitDeletion bool, timeout time.Duration) error {
+	propagation := metav1.DeletePropagationForeground
+	err := client.Delete(ctx, name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error deleting %s %s: %v", kind, name, err)
+	}
+	if !waitDeletion {
+		return nil
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if err := waitForDeletion(ctx, client, name); err != nil {
+		return fmt.Errorf("error waiting for %s %s to be deleted: %v", kind, name, err)
+	}
+	return nil
+}
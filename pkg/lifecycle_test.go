@@ -0,0 +1,73 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_selectSessions(t *testing.T) {
+	item := func(name, targetPod string) unstructured.Unstructured {
+		u := unstructured.Unstructured{}
+		u.SetName(name)
+		u.SetAnnotations(map[string]string{targetPodAnnotation: targetPod})
+		return u
+	}
+	items := []unstructured.Unstructured{
+		item("outyet-dw", "outyet"),
+		item("outyet-debug", "outyet"),
+		item("web-dw", "web"),
+	}
+	tests := []struct {
+		name      string
+		names     []string
+		all       bool
+		targetPod string
+		want      []string
+		wantErr   bool
+	}{
+		{
+			name:  "by name",
+			names: []string{"web-dw"},
+			want:  []string{"web-dw"},
+		},
+		{
+			name:    "unknown name",
+			names:   []string{"unknown-dw"},
+			wantErr: true,
+		},
+		{
+			name:      "by target pod",
+			targetPod: "outyet",
+			want:      []string{"outyet-dw", "outyet-debug"},
+		},
+		{
+			name: "all",
+			all:  true,
+			want: []string{"outyet-dw", "outyet-debug", "web-dw"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &LifecycleOptions{
+				names:     tt.names,
+				all:       tt.all,
+				targetPod: tt.targetPod,
+				clients:   &kubeClients{namespace: "dev"},
+			}
+			got, err := o.selectSessions(items)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("selectSessions() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			var gotNames []string
+			for _, s := range got {
+				gotNames = append(gotNames, s.name)
+			}
+			if !reflect.DeepEqual(gotNames, tt.want) {
+				t.Errorf("selectSessions() got = %v, want %v", gotNames, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/cli-runtime/pkg/printers"
+)
+
+var listExample = `
+	# List the debugging sessions in the current namespace
+	%[1]s debug-ide list
+
+	# List the debugging sessions in all namespaces, including the image and the git repository
+	%[1]s debug-ide list --all-namespaces -o wide`
+
+const outputWide = "wide"
+
+// ListOptions provides information required to list the debugging sessions
+type ListOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+	printFlags  *genericclioptions.PrintFlags
+
+	allNamespaces bool
+	clients       *kubeClients
+
+	genericiooptions.IOStreams
+}
+
+// NewListOptions provides an instance of ListOptions with default values
+func NewListOptions(configFlags *genericclioptions.ConfigFlags, streams genericiooptions.IOStreams) *ListOptions {
+	return &ListOptions{
+		configFlags: configFlags,
+		printFlags:  genericclioptions.NewPrintFlags(""),
+
+		IOStreams: streams,
+	}
+}
+
+// NewCmdList provides a cobra command wrapping ListOptions
+func NewCmdList(configFlags *genericclioptions.ConfigFlags, streams genericiooptions.IOStreams) *cobra.Command {
+	o := NewListOptions(configFlags, streams)
+
+	cmd := &cobra.Command{
+		Use:          "list [flags]",
+		Short:        "List the debugging sessions created by kubectl debug-ide.",
+		Example:      fmt.Sprintf(listExample, "kubectl"),
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			if err := o.Run(c.Context()); err != nil {
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&o.allNamespaces, "all-namespaces", "A", o.allNamespaces, "If present, list the debugging sessions across all namespaces")
+	o.printFlags.AddFlags(cmd)
+	cmd.Flags().Lookup("output").Usage = fmt.Sprintf("Output format. One of: (%s).",
+		strings.Join(append([]string{outputWide}, o.printFlags.AllowedFormats()...), ", "))
+
+	return cmd
+}
+
+// Complete sets all information required for listing the debugging sessions
+func (o *ListOptions) Complete() error {
+	var err error
+	o.clients, err = newKubeClients(o.configFlags)
+	return err
+}
+
+// Validate ensures that the output format is supported
+func (o *ListOptions) Validate() error {
+	if o.isTableOutput() {
+		return nil
+	}
+	_, err := o.printFlags.ToPrinter()
+	return err
+}
+
+// Run lists the DevWorkspaces, and the Pods of the native backend, created by the plugin
+func (o *ListOptions) Run(ctx context.Context) error {
+	namespace := o.clients.namespace
+	if o.allNamespaces {
+		namespace = ""
+	}
+
+	list, err := listAllSessions(ctx, o.clients, namespace)
+	if err != nil {
+		return err
+	}
+
+	if !o.isTableOutput() {
+		printer, err := o.printFlags.ToPrinter()
+		if err != nil {
+			return err
+		}
+		return printer.PrintObj(list, o.Out)
+	}
+
+	if len(list.Items) == 0 {
+		if o.allNamespaces {
+			fmt.Fprintln(o.ErrOut, "No debugging sessions found.")
+		} else {
+			fmt.Fprintf(o.ErrOut, "No debugging sessions found in %s namespace.\n", namespace)
+		}
+		return nil
+	}
+
+	sessions := make([]session, 0, len(list.Items))
+	for _, item := range list.Items {
+		sessions = append(sessions, sessionFromUnstructured(item))
+	}
+	return printSessions(o.Out, sessions, o.allNamespaces, *o.printFlags.OutputFormat == outputWide, time.Now())
+}
+
+func (o *ListOptions) isTableOutput() bool {
+	return *o.printFlags.OutputFormat == "" || *o.printFlags.OutputFormat == outputWide
+}
+
+func printSessions(out io.Writer, sessions []session, withNamespace, wide bool, now time.Time) error {
+	w := printers.GetNewTabWriter(out)
+
+	columns := []string{"NAME", "TARGET POD", "PHASE", "URL", "AGE"}
+	if withNamespace {
+		columns = append([]string{"NAMESPACE"}, columns...)
+	}
+	if wide {
+		columns = append(columns, "IMAGE", "GIT REPOSITORY")
+	}
+	fmt.Fprintln(w, strings.Join(columns, "\t"))
+
+	for _, s := range sessions {
+		row := []string{
+			s.name,
+			valueOrNone(s.targetPod),
+			valueOrNone(s.phase),
+			valueOrNone(s.url),
+			duration.HumanDuration(now.Sub(s.created)),
+		}
+		if withNamespace {
+			row = append([]string{s.namespace}, row...)
+		}
+		if wide {
+			row = append(row, valueOrNone(s.image), valueOrNone(s.gitRepository))
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+func valueOrNone(v string) string {
+	if v == "" {
+		return "<none>"
+	}
+	return v
+}
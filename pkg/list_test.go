@@ -0,0 +1,63 @@
+package pkg
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func Test_printSessions(t *testing.T) {
+	now := time.Date(2024, 12, 1, 10, 0, 0, 0, time.UTC)
+	sessions := []session{
+		{
+			namespace:     "dev",
+			name:          "outyet-dw",
+			targetPod:     "outyet",
+			image:         "quay.io/devfile/universal-developer-image:ubi8-latest",
+			gitRepository: "https://github.com/l0rd/outyet",
+			phase:         "Running",
+			url:           "https://outyet.example.com",
+			created:       now.Add(-2 * time.Hour),
+		},
+		{
+			namespace: "dev",
+			name:      "web-dw",
+			targetPod: "web",
+			created:   now.Add(-30 * time.Second),
+		},
+	}
+	tests := []struct {
+		name          string
+		withNamespace bool
+		wide          bool
+		want          string
+	}{
+		{
+			name: "default",
+			want: `NAME        TARGET POD   PHASE     URL                          AGE
+outyet-dw   outyet       Running   https://outyet.example.com   120m
+web-dw      web          <none>    <none>                       30s
+`,
+		},
+		{
+			name:          "all namespaces wide",
+			withNamespace: true,
+			wide:          true,
+			want: `NAMESPACE   NAME        TARGET POD   PHASE     URL                          AGE    IMAGE                                                   GIT REPOSITORY
+dev         outyet-dw   outyet       Running   https://outyet.example.com   120m   quay.io/devfile/universal-developer-image:ubi8-latest   https://github.com/l0rd/outyet
+dev         web-dw      web          <none>    <none>                       30s    <none>                                                  <none>
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			if err := printSessions(out, sessions, tt.withNamespace, tt.wide, now); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("printSessions() got:\n%s\nwant:\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+const localAddress = "localhost"
+
+// podEndpoint is a TCP port of a container of the debugging Pod
+type podEndpoint struct {
+	container string
+	name      string
+	port      int
+}
+
+// podEndpoints returns the TCP ports of the containers of the Pod
+func podEndpoints(pod *corev1.Pod) []podEndpoint {
+	var endpoints []podEndpoint
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.Protocol != "" && p.Protocol != corev1.ProtocolTCP {
+				continue
+			}
+			name := p.Name
+			if name == "" {
+				name = "port" + strconv.Itoa(int(p.ContainerPort))
+			}
+			endpoints = append(endpoints, podEndpoint{
+				container: c.Name,
+				name:      name,
+				port:      int(p.ContainerPort),
+			})
+		}
+	}
+	return endpoints
+}
+
+// portForwardSpecs returns the ports in the format expected by portforward.
+// The local port is the same as the remote one if it's available, otherwise
+// it's picked randomly.
+func portForwardSpecs(endpoints []podEndpoint, available func(port int) bool) []string {
+	specs := make([]string, 0, len(endpoints))
+	for _, e := range endpoints {
+		if available(e.port) {
+			specs = append(specs, fmt.Sprintf("%d:%d", e.port, e.port))
+		} else {
+			specs = append(specs, fmt.Sprintf(":%d", e.port))
+		}
+	}
+	return specs
+}
+
+func localPortAvailable(port int) bool {
+	l, err := net.Listen("tcp", net.JoinHostPort(localAddress, strconv.Itoa(port)))
+	if err != nil {
+		return false
+	}
+	_ = l.Close()
+	return true
+}
+
+// localURL returns the URL of a forwarded port, keeping the query of the
+// remote URL if any. The remote path is dropped as it's the routing prefix.
+func localURL(port int, remoteURL string) string {
+	u := &url.URL{Scheme: "http", Host: net.JoinHostPort(localAddress, strconv.Itoa(port)), Path: "/"}
+	if r, err := url.Parse(remoteURL); err == nil {
+		u.RawQuery = r.RawQuery
+	}
+	return u.String()
+}
+
+// forwardPorts forwards the endpoints of the Pod podName on localhost and
+// prints their URLs. ideContainer and idePort identify the IDE endpoint,
+// whose URL is opened in the browser if requested. It blocks until ctx
+// is done or the connection to the Pod is lost.
+func (o *DebugIDEOptions) forwardPorts(
+	ctx context.Context,
+	podName string,
+	endpoints []podEndpoint,
+	ideContainer string,
+	idePort int,
+	ideRemoteURL string,
+) error {
+	if len(endpoints) == 0 {
+		return fmt.Errorf("no ports to forward in pod %s", podName)
+	}
+
+	dialer, err := portForwardDialer(o.clients, podName)
+	if err != nil {
+		return err
+	}
+
+	stopCh := make(chan struct{})
+	readyCh := make(chan struct{})
+	pf, err := portforward.NewOnAddresses(dialer, []string{localAddress},
+		portForwardSpecs(endpoints, localPortAvailable), stopCh, readyCh, io.Discard, o.ErrOut)
+	if err != nil {
+		return fmt.Errorf("error forwarding the ports of pod %s: %v", podName, err)
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- pf.ForwardPorts()
+	}()
+
+	select {
+	case <-readyCh:
+	case err := <-errCh:
+		return fmt.Errorf("error forwarding the ports of pod %s: %v", podName, err)
+	case <-ctx.Done():
+		close(stopCh)
+		return nil
+	}
+
+	forwarded, err := pf.GetPorts()
+	if err != nil {
+		close(stopCh)
+		return fmt.Errorf("error getting the forwarded ports of pod %s: %v", podName, err)
+	}
+	local := make(map[int]int, len(forwarded))
+	for _, p := range forwarded {
+		local[int(p.Remote)] = int(p.Local)
+	}
+
+	ideURL := ""
+	for _, e := range endpoints {
+		if e.container == ideContainer && e.port == idePort {
+			ideURL = localURL(local[e.port], ideRemoteURL)
+		}
+	}
+	if ideURL != "" {
+		fmt.Fprintf(o.Out, "🐞 open the following link ⬇️ and start debugging\n\n")
+		fmt.Fprintf(o.Out, "%s\n\n", ideURL)
+	}
+	fmt.Fprintf(o.Out, "🔌 forwarding the ports of pod %s, press Ctrl-C to stop\n", podName)
+	for _, e := range endpoints {
+		if e.container == ideContainer && e.port == idePort {
+			continue
+		}
+		fmt.Fprintf(o.Out, "%s%s/%s: %s\n", progressIndent, e.container, e.name, localURL(local[e.port], ""))
+	}
+
+	if o.openBrowser && ideURL != "" {
+		if err := openURL(ideURL); err != nil {
+			fmt.Fprintf(o.ErrOut, "cannot open the browser: %v\n", err)
+		}
+	}
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return fmt.Errorf("lost connection to pod %s: %v", podName, err)
+		}
+		return nil
+	case <-ctx.Done():
+		close(stopCh)
+		<-errCh
+		return nil
+	}
+}
+
+// portForwardDialer returns a dialer for the portforward subresource of the Pod podName
+func portForwardDialer(clients *kubeClients, podName string) (httpstream.Dialer, error) {
+	transport, upgrader, err := spdy.RoundTripperFor(clients.config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the port-forward transport: %v", err)
+	}
+	req := clients.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(clients.namespace).
+		Name(podName).
+		SubResource("portforward")
+	return spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, req.URL()), nil
+}
+
+// ideEndpoint returns the port of the container that runs the IDE
+func ideEndpoint(endpoints []podEndpoint, container string) (int, bool) {
+	for _, e := range endpoints {
+		if e.container == container {
+			return e.port, true
+		}
+	}
+	return 0, false
+}
+
+// openURL opens u in the default browser
+func openURL(u string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", u)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", u)
+	default:
+		cmd = exec.Command("xdg-open", u)
+	}
+	return cmd.Start()
+}
@@ -0,0 +1,75 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func Test_podEndpoints(t *testing.T) {
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  "outyet",
+					Ports: []corev1.ContainerPort{{ContainerPort: 8080}, {Name: "dns", ContainerPort: 53, Protocol: corev1.ProtocolUDP}},
+				},
+				{
+					Name:  "che-code-runtime-description",
+					Ports: []corev1.ContainerPort{{Name: "che-code", ContainerPort: 3100, Protocol: corev1.ProtocolTCP}},
+				},
+			},
+		},
+	}
+	want := []podEndpoint{
+		{container: "outyet", name: "port8080", port: 8080},
+		{container: "che-code-runtime-description", name: "che-code", port: 3100},
+	}
+	if got := podEndpoints(pod); !reflect.DeepEqual(got, want) {
+		t.Errorf("podEndpoints() = %v, want %v", got, want)
+	}
+}
+
+func Test_portForwardSpecs(t *testing.T) {
+	endpoints := []podEndpoint{{port: 8080}, {port: 3100}}
+	available := func(port int) bool { return port != 8080 }
+	want := []string{":8080", "3100:3100"}
+	if got := portForwardSpecs(endpoints, available); !reflect.DeepEqual(got, want) {
+		t.Errorf("portForwardSpecs() = %v, want %v", got, want)
+	}
+}
+
+func Test_localURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		port      int
+		remoteURL string
+		want      string
+	}{
+		{
+			name: "no remote URL",
+			port: 8080,
+			want: "http://localhost:8080/",
+		},
+		{
+			name:      "remote URL query is kept",
+			port:      3100,
+			remoteURL: "https://example.com/user/outyet-dw/3100/?tkn=eclipse-che",
+			want:      "http://localhost:3100/?tkn=eclipse-che",
+		},
+		{
+			name:      "remote URL without path",
+			port:      3100,
+			remoteURL: "https://workspace1234-che-code.example.com",
+			want:      "http://localhost:3100/",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := localURL(tt.port, tt.remoteURL); got != tt.want {
+				t.Errorf("localURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
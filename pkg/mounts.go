@@ -0,0 +1,318 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// The DevWorkspace Operator rejects the env of the container-overrides. The
+// env variables that reference a key of a ConfigMap or a Secret are copied
+// in a ConfigMap and a Secret of the session, that the container imports
+// through envFrom. The volumes are mounted in the containers of the session
+// only: the emptyDir volumes are devfile volumes, the others are added to
+// the pod-overrides and mounted through the container-overrides.
+const (
+	envCopySuffix = "-env"
+	configMapKind = "ConfigMap"
+	secretKind    = "Secret"
+)
+
+// envCopyName is the name of the ConfigMap and of the Secret with
+// the env variables of a container that reference their keys
+func envCopyName(dwName, container string) string {
+	return dwName + "-" + container + envCopySuffix
+}
+
+// devfileVolumes returns the names of the volumes that are devfile volumes:
+// the emptyDir volumes that are never mounted read-only or with a subPath,
+// that a devfile volume mount doesn't support
+func devfileVolumes(containers []ContainerInfo) map[string]bool {
+	volumes := map[string]bool{}
+	overridden := map[string]bool{}
+	for _, c := range containers {
+		for _, vol := range c.volumes {
+			if !vol.ephemeral || vol.subPath != "" || vol.readOnly {
+				overridden[vol.name] = true
+				continue
+			}
+			volumes[vol.name] = true
+		}
+	}
+	for name := range overridden {
+		delete(volumes, name)
+	}
+	return volumes
+}
+
+// isDownwardAPIEnv is true if an env variable references a field of the Pod
+// or a resource of the container, that aren't copied
+func isDownwardAPIEnv(e ContainerEnv) bool {
+	return e.valueFrom != nil && (e.valueFrom.FieldRef != nil || e.valueFrom.ResourceFieldRef != nil)
+}
+
+// warnDownwardAPIEnv warns about the env variables of the containers that
+// reference a field of the Pod or a resource of the container, that the
+// DevWorkspace Operator doesn't allow to set
+func (o *DebugIDEOptions) warnDownwardAPIEnv(containers []ContainerInfo) {
+	for _, c := range containers {
+		for _, e := range c.env {
+			if isDownwardAPIEnv(e) {
+				fmt.Fprintf(o.ErrOut, "⚠️ the env variable %s of container %s references a field of the Pod and isn't copied, "+
+					"use --backend native to copy it\n", e.name, c.name)
+			}
+		}
+	}
+}
+
+func isOptional(optional *bool) bool {
+	return optional != nil && *optional
+}
+
+// configReader reads, once, the ConfigMaps and Secrets of the namespace
+type configReader struct {
+	clients *kubeClients
+	data    map[string]map[string][]byte
+}
+
+// read returns the data of a ConfigMap or a Secret, false if it doesn't exist
+func (r *configReader) read(ctx context.Context, kind, name string) (map[string][]byte, bool, error) {
+	id := kind + "/" + name
+	if data, ok := r.data[id]; ok {
+		return data, data != nil, nil
+	}
+
+	var data map[string][]byte
+	var err error
+	if kind == configMapKind {
+		var cm *corev1.ConfigMap
+		cm, err = r.clients.clientset.CoreV1().ConfigMaps(r.clients.namespace).Get(ctx, name, metav1.GetOptions{})
+		if err == nil {
+			data = make(map[string][]byte, len(cm.Data)+len(cm.BinaryData))
+			for k, v := range cm.Data {
+				data[k] = []byte(v)
+			}
+			for k, v := range cm.BinaryData {
+				data[k] = v
+			}
+		}
+	} else {
+		var s *corev1.Secret
+		s, err = r.clients.clientset.CoreV1().Secrets(r.clients.namespace).Get(ctx, name, metav1.GetOptions{})
+		if err == nil {
+			data = s.Data
+			if data == nil {
+				data = map[string][]byte{}
+			}
+		}
+	}
+	if k8serrors.IsNotFound(err) {
+		r.data[id] = nil
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("error getting %s %s: %v", kind, name, err)
+	}
+	r.data[id] = data
+	return data, true, nil
+}
+
+// envCopies returns the copies of the ConfigMaps and Secrets keys
+// referenced by the env variables of the containers
+func envCopies(
+	ctx context.Context,
+	clients *kubeClients,
+	dwName string,
+	containers []ContainerInfo,
+) ([]*corev1.ConfigMap, []*corev1.Secret, error) {
+	r := &configReader{clients: clients, data: map[string]map[string][]byte{}}
+	var configMaps []*corev1.ConfigMap
+	var secrets []*corev1.Secret
+
+	for _, c := range containers {
+		var fromConfigMap, fromSecret map[string][]byte
+		for _, e := range c.env {
+			if e.valueFrom == nil {
+				continue
+			}
+			var kind, name, key string
+			var optional bool
+			switch {
+			case e.valueFrom.ConfigMapKeyRef != nil:
+				ref := e.valueFrom.ConfigMapKeyRef
+				kind, name, key, optional = configMapKind, ref.Name, ref.Key, isOptional(ref.Optional)
+				if fromConfigMap == nil {
+					fromConfigMap = map[string][]byte{}
+				}
+			case e.valueFrom.SecretKeyRef != nil:
+				ref := e.valueFrom.SecretKeyRef
+				kind, name, key, optional = secretKind, ref.Name, ref.Key, isOptional(ref.Optional)
+				if fromSecret == nil {
+					fromSecret = map[string][]byte{}
+				}
+			default:
+				continue
+			}
+			data, _, err := r.read(ctx, kind, name)
+			if err != nil {
+				return nil, nil, err
+			}
+			value, found := data[key]
+			if !found {
+				if optional {
+					continue
+				}
+				return nil, nil, fmt.Errorf("the key %s of %s %s, referenced by the env variable %s of container %s, not found",
+					key, kind, name, e.name, c.name)
+			}
+			if kind == configMapKind {
+				fromConfigMap[e.name] = value
+			} else {
+				fromSecret[e.name] = value
+			}
+		}
+		if fromConfigMap != nil {
+			configMaps = append(configMaps, configMapCopy(envCopyName(dwName, c.name), dwName, fromConfigMap))
+		}
+		if fromSecret != nil {
+			secrets = append(secrets, secretCopy(envCopyName(dwName, c.name), dwName, fromSecret))
+		}
+	}
+	return configMaps, secrets, nil
+}
+
+func copyLabels(dwName string) map[string]string {
+	l := sessionLabels()
+	l[sessionNameLabel] = dwName
+	return l
+}
+
+func configMapCopy(name, dwName string, data map[string][]byte) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       configMapKind,
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: copyLabels(dwName),
+		},
+	}
+	for k, v := range data {
+		if utf8.Valid(v) {
+			if cm.Data == nil {
+				cm.Data = map[string]string{}
+			}
+			cm.Data[k] = string(v)
+			continue
+		}
+		if cm.BinaryData == nil {
+			cm.BinaryData = map[string][]byte{}
+		}
+		cm.BinaryData[k] = v
+	}
+	return cm
+}
+
+func secretCopy(name, dwName string, data map[string][]byte) *corev1.Secret {
+	return &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       secretKind,
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: copyLabels(dwName),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+}
+
+// configCopiesObjects returns the copies of the session, the ConfigMaps first
+func (o *DebugIDEOptions) configCopiesObjects() []runtime.Object {
+	objs := make([]runtime.Object, 0, len(o.configMapCopies)+len(o.secretCopies))
+	for _, cm := range o.configMapCopies {
+		objs = append(objs, cm)
+	}
+	for _, s := range o.secretCopies {
+		objs = append(objs, s)
+	}
+	return objs
+}
+
+// applyConfigCopies creates, or updates, the copies of the ConfigMaps and Secrets
+func (o *DebugIDEOptions) applyConfigCopies(ctx context.Context) error {
+	for _, cm := range o.configMapCopies {
+		if err := applyConfigMap(ctx, o.clients, cm); err != nil {
+			return err
+		}
+		fmt.Fprintf(o.Out, "📄 applied configmap %s in namespace %s.\n", cm.Name, o.clients.namespace)
+	}
+	for _, s := range o.secretCopies {
+		if err := applySecret(ctx, o.clients, s); err != nil {
+			return err
+		}
+		fmt.Fprintf(o.Out, "🔐 applied secret %s in namespace %s.\n", s.Name, o.clients.namespace)
+	}
+	return nil
+}
+
+// applyConfigMap creates, or updates if it already exists, a ConfigMap. The
+// ConfigMaps that weren't created by kubectl debug-ide are never replaced.
+func applyConfigMap(ctx context.Context, clients *kubeClients, cm *corev1.ConfigMap) error {
+	configMaps := clients.clientset.CoreV1().ConfigMaps(clients.namespace)
+	existing, err := configMaps.Get(ctx, cm.Name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		if _, err := configMaps.Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("error creating ConfigMap %s: %v", cm.Name, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting ConfigMap %s: %v", cm.Name, err)
+	}
+	if existing.Labels[managedByLabel] != managedByValue {
+		return fmt.Errorf("the ConfigMap %s wasn't created by kubectl debug-ide and cannot be replaced", cm.Name)
+	}
+	cm.ResourceVersion = existing.ResourceVersion
+	if _, err := configMaps.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("error updating ConfigMap %s: %v", cm.Name, err)
+	}
+	return nil
+}
+
+// deleteConfigCopies deletes the copies of the ConfigMaps and Secrets of a session
+func deleteConfigCopies(ctx context.Context, clients *kubeClients, dwName string) error {
+	selector := labels.SelectorFromSet(copyLabels(dwName)).String()
+	opts := metav1.ListOptions{LabelSelector: selector}
+
+	configMaps := clients.clientset.CoreV1().ConfigMaps(clients.namespace)
+	cmList, err := configMaps.List(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("error listing the ConfigMaps of %s: %v", dwName, err)
+	}
+	for _, cm := range cmList.Items {
+		if err := configMaps.Delete(ctx, cm.Name, metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("error deleting ConfigMap %s: %v", cm.Name, err)
+		}
+	}
+
+	secrets := clients.clientset.CoreV1().Secrets(clients.namespace)
+	secretList, err := secrets.List(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("error listing the Secrets of %s: %v", dwName, err)
+	}
+	for _, s := range secretList.Items {
+		if err := secrets.Delete(ctx, s.Name, metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("error deleting Secret %s: %v", s.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package pkg
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_devfileVolumes(t *testing.T) {
+	containers := []ContainerInfo{
+		{
+			name: "app",
+			volumes: []ContainerVolume{
+				{name: "cache", path: "/cache", ephemeral: true},
+				{name: "scratch", path: "/scratch", ephemeral: true},
+				{name: "config", path: "/etc/app"},
+				{name: "data", path: "/data"},
+			},
+		},
+		{
+			name: "sidecar",
+			volumes: []ContainerVolume{
+				{name: "cache", path: "/cache", ephemeral: true},
+				{name: "scratch", path: "/scratch", subPath: "sidecar", ephemeral: true},
+			},
+		},
+	}
+	want := map[string]bool{"cache": true}
+	if got := devfileVolumes(containers); !reflect.DeepEqual(got, want) {
+		t.Errorf("devfileVolumes() = %v, want %v", got, want)
+	}
+}
+
+func Test_warnDownwardAPIEnv(t *testing.T) {
+	streams, _, _, errOut := genericiooptions.NewTestIOStreams()
+	o := NewDebugIDEOptions(streams)
+	o.warnDownwardAPIEnv([]ContainerInfo{{name: "app", env: []ContainerEnv{
+		{name: "MODE", value: "debug"},
+		{name: "NODE_NAME", valueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"}}},
+		{name: "PASSWORD", valueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{Key: "password"}}},
+	}}})
+	if got := errOut.String(); strings.Count(got, "⚠️") != 1 || !strings.Contains(got, "NODE_NAME of container app") {
+		t.Errorf("warnDownwardAPIEnv() output = %q", got)
+	}
+}
+
+func Test_envCopies(t *testing.T) {
+	optional := true
+	clientset := fake.NewClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "dev"},
+			Data:       map[string]string{"log-level": "debug", "app.yaml": "port: 8080"},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-tls", Namespace: "dev"},
+			Data:       map[string][]byte{"tls.crt": []byte("CERT"), "password": []byte("s3cr3t")},
+		},
+	)
+	clients := &kubeClients{namespace: "dev", clientset: clientset}
+	containers := []ContainerInfo{
+		{
+			name: "app",
+			env: []ContainerEnv{
+				{name: "MODE", value: "debug"},
+				{name: "LOG_LEVEL", valueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "app-config"}, Key: "log-level",
+				}}},
+				{name: "TOKEN", valueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "other"}, Key: "token", Optional: &optional,
+				}}},
+				{name: "NODE_NAME", valueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"}}},
+			},
+		},
+		{
+			name: "sidecar",
+			env: []ContainerEnv{
+				{name: "PASSWORD", valueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "app-tls"}, Key: "password",
+				}}},
+			},
+		},
+	}
+
+	configMaps, secrets, err := envCopies(context.Background(), clients, "app-dw", containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(configMaps) != 1 || configMaps[0].Name != "app-dw-app-env" ||
+		!reflect.DeepEqual(configMaps[0].Data, map[string]string{"LOG_LEVEL": "debug"}) {
+		t.Fatalf("envCopies() ConfigMaps = %v", configMaps)
+	}
+	wantSecrets := []struct {
+		name string
+		data map[string][]byte
+	}{
+		{name: "app-dw-app-env", data: map[string][]byte{}},
+		{name: "app-dw-sidecar-env", data: map[string][]byte{"PASSWORD": []byte("s3cr3t")}},
+	}
+	if len(secrets) != len(wantSecrets) {
+		t.Fatalf("envCopies() returned %d Secrets, want %d", len(secrets), len(wantSecrets))
+	}
+	for i, want := range wantSecrets {
+		s := secrets[i]
+		if s.Name != want.name || !reflect.DeepEqual(s.Data, want.data) {
+			t.Errorf("envCopies() Secret %s data = %v, want %s %v", s.Name, s.Data, want.name, want.data)
+		}
+	}
+	for _, l := range []map[string]string{configMaps[0].Labels, secrets[0].Labels, secrets[1].Labels} {
+		if l[managedByLabel] != managedByValue || l[sessionNameLabel] != "app-dw" {
+			t.Errorf("envCopies() labels = %v", l)
+		}
+		if _, mounted := l[mountToDevWorkspaceLabel]; mounted {
+			t.Errorf("envCopies() copy mounted in every DevWorkspace of the namespace")
+		}
+	}
+
+	containers[0].env[2].valueFrom.SecretKeyRef.Optional = nil
+	if _, _, err := envCopies(context.Background(), clients, "app-dw", containers); err == nil {
+		t.Errorf("envCopies() with a missing required key, want an error")
+	}
+}
+
+func Test_deleteConfigCopies(t *testing.T) {
+	copies := []*corev1.ConfigMap{
+		configMapCopy("app-dw-app-env", "app-dw", nil),
+		configMapCopy("other-dw-app-env", "other-dw", nil),
+	}
+	managed := secretCopy("app-dw-tls-volume", "app-dw", nil)
+	managed.Namespace = "dev"
+	unmanaged := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "app-dw-config-volume", Namespace: "dev"}}
+	clientset := fake.NewClientset(managed, unmanaged)
+	for _, cm := range copies {
+		cm.Namespace = "dev"
+		if _, err := clientset.CoreV1().ConfigMaps("dev").Create(context.Background(), cm, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clients := &kubeClients{namespace: "dev", clientset: clientset}
+	ctx := context.Background()
+
+	if err := deleteConfigCopies(ctx, clients, "app-dw"); err != nil {
+		t.Fatal(err)
+	}
+	configMaps, err := clientset.CoreV1().ConfigMaps("dev").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configMaps.Items) != 1 || configMaps.Items[0].Name != "other-dw-app-env" {
+		t.Errorf("deleteConfigCopies() left the ConfigMaps %v, want other-dw-app-env", configMaps.Items)
+	}
+	secrets, err := clientset.CoreV1().Secrets("dev").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets.Items) != 1 || secrets.Items[0].Name != unmanaged.Name {
+		t.Errorf("deleteConfigCopies() left the Secrets %v, want %s", secrets.Items, unmanaged.Name)
+	}
+}
@@ -0,0 +1,337 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// The native backend renders the copy of the target Pod as a plain Pod,
+// the way the DevWorkspace Operator does: an init container copies che-code
+// in a volume, another one clones the git repositories and the CDE container
+// runs che-code from that volume.
+const (
+	backendLabel        = "debug-ide.devfile.io/backend"
+	sessionNameLabel    = "debug-ide.devfile.io/session"
+	nativeIDEPort       = ephemeralIDEPort
+	nativeIDEPortName   = "che-code"
+	projectsVolumeName  = "projects"
+	cheCodeVolumeName   = "checode"
+	cheCodeMountPath    = "/checode"
+	cheCodeInjectorName = "che-code-injector"
+	projectCloneName    = "project-clone"
+	projectCloneScript  = `set -e
+while [ $# -ge 3 ]; do
+  if [ ! -d "$2" ]; then
+    git clone "$1" "$2"
+    if [ -n "$3" ]; then
+      git -C "$2" checkout "$3"
+    fi
+  fi
+  shift 3
+done
+`
+)
+
+var podsResource = corev1.SchemeGroupVersion.WithResource("pods")
+
+// nativeSessionLabels are the labels of the Pods of the native backend
+func nativeSessionLabels(name string) map[string]string {
+	l := sessionLabels()
+	l[backendLabel] = backendNative
+	l[sessionNameLabel] = name
+	return l
+}
+
+// nativePod returns the copy of the target Pod with the IDE
+func nativePod(o DebugIDEOptions) (*corev1.Pod, error) {
+	name := o.devWorkspaceName()
+	projectsMount := corev1.VolumeMount{Name: projectsVolumeName, MountPath: projectsRoot}
+
+	initContainers := []corev1.Container{{
+		Name:         cheCodeInjectorName,
+		Image:        defaultEphemeralImage,
+		Command:      []string{"/entrypoint-init-container.sh"},
+		VolumeMounts: []corev1.VolumeMount{{Name: cheCodeVolumeName, MountPath: cheCodeMountPath}},
+	}}
+	clone, err := projectCloneContainer(o)
+	if err != nil {
+		return nil, err
+	}
+	if clone != nil {
+		initContainers = append(initContainers, *clone)
+	}
+	// The init containers of the target Pod run once the projects are cloned
+	for _, ctr := range o.targetPodInitContainers {
+		ctr.endpoints = nil
+		c, err := podContainer(ctr)
+		if err != nil {
+			return nil, err
+		}
+		c.VolumeMounts = append(c.VolumeMounts, projectsMount)
+		initContainers = append(initContainers, c)
+	}
+
+	cde, err := nativeCDEContainer(o)
+	if err != nil {
+		return nil, err
+	}
+	containers := []corev1.Container{cde}
+	for _, ctr := range o.targetPodContainers {
+		c, err := podContainer(ctr)
+		if err != nil {
+			return nil, err
+		}
+		c.VolumeMounts = append(c.VolumeMounts, projectsMount)
+		containers = append(containers, c)
+	}
+
+	volumes := append([]corev1.Volume{}, o.targetPodVolumes...)
+	for _, v := range []string{projectsVolumeName, cheCodeVolumeName} {
+		volumes = append(volumes, corev1.Volume{
+			Name:         v,
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		})
+	}
+
+	p := &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Pod",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      nativeSessionLabels(name),
+			Annotations: sessionAnnotations(o),
+		},
+		Spec: corev1.PodSpec{
+			InitContainers: initContainers,
+			Containers:     containers,
+			Volumes:        volumes,
+		},
+	}
+	if o.shareProcesses {
+		share := true
+		p.Spec.ShareProcessNamespace = &share
+	}
+	if o.sameNode && o.targetPod != nil {
+		affinity := sameNodeAffinity(o.targetPod.Spec.NodeName)
+		p.Spec.Affinity = &affinity
+	}
+	return p, nil
+}
+
+// nativeCDEContainer is the container running the --image and the IDE
+func nativeCDEContainer(o DebugIDEOptions) (corev1.Container, error) {
+	resources, err := containerResources(defaultDevMemoryRequest, defaultDevMemoryLimit, defaultDevCPURequest, defaultDevCPULimit)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+	env := []corev1.EnvVar{
+		{Name: "PROJECTS_ROOT", Value: projectsRoot},
+		{Name: "PROJECT_SOURCE", Value: o.debugProjectDir()},
+	}
+	for _, e := range o.ide.env {
+		env = append(env, corev1.EnvVar{Name: e.Name, Value: e.Value})
+	}
+	c := corev1.Container{
+		Name:    defaultDevContainerName,
+		Image:   o.debugImage,
+		Command: []string{path.Join(cheCodeMountPath, "entrypoint-volume.sh")},
+		Env:     env,
+		Ports: []corev1.ContainerPort{{
+			Name:          nativeIDEPortName,
+			ContainerPort: nativeIDEPort,
+			Protocol:      corev1.ProtocolTCP,
+		}},
+		Resources: resources,
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: projectsVolumeName, MountPath: projectsRoot},
+			{Name: cheCodeVolumeName, MountPath: cheCodeMountPath},
+		},
+		WorkingDir: projectsRoot,
+	}
+	if debuggerNeedsPtrace(o.debugLanguage) {
+		c.SecurityContext = ptraceSecurityContext(o.debuggerRunAsUser())
+	}
+	return c, nil
+}
+
+// projectCloneContainer is the init container that clones the git
+// repositories and writes the launch.json, nil if there is nothing to do.
+// The repositories are passed as (remote, folder, revision) arguments.
+func projectCloneContainer(o DebugIDEOptions) (*corev1.Container, error) {
+	args := make([]string, 0, 3*len(o.gitRepositories))
+	for _, repo := range o.gitRepositories {
+		name, err := projectName(repo.remote)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, repo.remote, path.Join(projectsRoot, name), repo.revision)
+	}
+	if len(args) == 0 && len(o.launchJSON) == 0 {
+		return nil, nil
+	}
+	script := projectCloneScript
+	if len(o.launchJSON) > 0 {
+		script += launchCommandLine(o.debugProjectDir(), o.launchJSON) + "\n"
+	}
+	return &corev1.Container{
+		Name:         projectCloneName,
+		Image:        o.debugImage,
+		Command:      []string{"/bin/sh", "-c", script, projectCloneName},
+		Args:         args,
+		VolumeMounts: []corev1.VolumeMount{{Name: projectsVolumeName, MountPath: projectsRoot}},
+	}, nil
+}
+
+// podContainer returns the copy of a container of the target Pod
+func podContainer(ctr ContainerInfo) (corev1.Container, error) {
+	resources, err := containerResources(ctr.memoryRequest, ctr.memoryLimit, ctr.cpuRequest, ctr.cpuLimit)
+	if err != nil {
+		return corev1.Container{}, fmt.Errorf("invalid resources of container %s: %v", ctr.name, err)
+	}
+	c := corev1.Container{
+		Name:       ctr.name,
+		Image:      ctr.image,
+		Command:    ctr.command,
+		Args:       ctr.args,
+		WorkingDir: ctr.workingDir,
+		EnvFrom:    ctr.envFrom,
+		Resources:  resources,
+
+		LivenessProbe:  ctr.livenessProbe,
+		ReadinessProbe: ctr.readinessProbe,
+		StartupProbe:   ctr.startupProbe,
+		Lifecycle:      ctr.lifecycle,
+	}
+	for _, e := range ctr.env {
+		c.Env = append(c.Env, corev1.EnvVar{Name: e.name, Value: e.value, ValueFrom: e.valueFrom})
+	}
+	for _, e := range ctr.endpoints {
+		c.Ports = append(c.Ports, corev1.ContainerPort{
+			Name:          e.name,
+			ContainerPort: int32(e.targetPort),
+			Protocol:      e.protocol,
+		})
+	}
+	for _, v := range ctr.volumes {
+		c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{
+			Name:      v.name,
+			MountPath: v.path,
+			SubPath:   v.subPath,
+			ReadOnly:  v.readOnly,
+		})
+	}
+	return c, nil
+}
+
+// containerResources parses the memory and CPU requests and limits,
+// the empty ones are not set
+func containerResources(memoryRequest, memoryLimit, cpuRequest, cpuLimit string) (corev1.ResourceRequirements, error) {
+	r := corev1.ResourceRequirements{}
+	for _, q := range []struct {
+		list  *corev1.ResourceList
+		name  corev1.ResourceName
+		value string
+	}{
+		{&r.Requests, corev1.ResourceMemory, memoryRequest},
+		{&r.Limits, corev1.ResourceMemory, memoryLimit},
+		{&r.Requests, corev1.ResourceCPU, cpuRequest},
+		{&r.Limits, corev1.ResourceCPU, cpuLimit},
+	} {
+		if q.value == "" {
+			continue
+		}
+		v, err := resource.ParseQuantity(q.value)
+		if err != nil {
+			return corev1.ResourceRequirements{}, err
+		}
+		if *q.list == nil {
+			*q.list = corev1.ResourceList{}
+		}
+		(*q.list)[q.name] = v
+	}
+	return r, nil
+}
+
+// nativeBackend creates the copy as a plain Pod, for the clusters
+// where the DevWorkspace Operator isn't installed
+type nativeBackend struct {
+	o *DebugIDEOptions
+}
+
+func (b *nativeBackend) objects() ([]runtime.Object, error) {
+	p, err := nativePod(*b.o)
+	if err != nil {
+		return nil, fmt.Errorf("error generating pod: %v", err)
+	}
+	return []runtime.Object{p}, nil
+}
+
+func (b *nativeBackend) ideContainer() string {
+	return defaultDevContainerName
+}
+
+// start creates the Pod, replacing the one of a previous session if
+// requested, and waits for it to be ready
+func (b *nativeBackend) start(ctx context.Context) (*corev1.Pod, string, error) {
+	o := b.o
+	pod, err := nativePod(*o)
+	if err != nil {
+		return nil, "", fmt.Errorf("error generating pod: %v", err)
+	}
+
+	namespace := o.clients.namespace
+	clientset := o.clients.clientset
+	pods := clientset.CoreV1().Pods(namespace)
+
+	existing, err := pods.Get(ctx, pod.Name, metav1.GetOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, "", fmt.Errorf("error checking for the existence of a Pod named %s: %v", pod.Name, err)
+	}
+	if err == nil {
+		if !o.replace {
+			return nil, "", fmt.Errorf("a Pod named %s already exists in namespace %s: choose another name with --copy-to or replace it with --replace", pod.Name, namespace)
+		}
+		if existing.Labels[managedByLabel] != managedByValue {
+			return nil, "", fmt.Errorf("the Pod %s wasn't created by kubectl debug-ide and cannot be replaced", pod.Name)
+		}
+		client := o.clients.dynClient.Resource(podsResource).Namespace(namespace)
+		if err := deleteAndWait(ctx, client, "Pod", pod.Name, true, o.timeout); err != nil {
+			return nil, "", err
+		}
+		fmt.Fprintf(o.Out, "🗑️ deleted pod %s in namespace %s.\n", pod.Name, namespace)
+	}
+
+	created, err := pods.Create(ctx, pod, metav1.CreateOptions{})
+	if err != nil {
+		return nil, "", fmt.Errorf("error creating pod %s: %v", pod.Name, err)
+	}
+	fmt.Fprintf(o.Out, "⌨️ created pod %s in namespace %s.\n", created.Name, namespace)
+
+	waitCtx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+
+	progress := newProgressReporter(o.Out, created.CreationTimestamp.Time)
+
+	// Report the Events of the Pod while waiting
+	eventsCtx, stopEvents := context.WithCancel(waitCtx)
+	defer stopEvents()
+	go progress.watchEvents(eventsCtx, clientset, namespace, sessionEventFilter(created.Name, ""))
+
+	progress.start("🥑 waiting for the pod %s to be ready...", created.Name)
+	p, err := waitForPodReady(waitCtx, clientset, namespace, sessionNameLabel+"="+created.Name, progress)
+	if err != nil {
+		return nil, "", o.waitError(progress, "the pod "+created.Name+" is not ready", err)
+	}
+	progress.done("done\n")
+	progress.close()
+	return p, "", nil
+}
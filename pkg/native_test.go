@@ -0,0 +1,133 @@
+package pkg
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	dwv1alpha2 "github.com/devfile/api/v2/pkg/apis/workspaces/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func Test_devWorkspaceInstalled(t *testing.T) {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	installed, err := devWorkspaceInstalled(mapper)
+	if err != nil || installed {
+		t.Errorf("devWorkspaceInstalled() = %v, %v, want false without the CRDs", installed, err)
+	}
+
+	mapper.Add(schema.GroupVersionKind{Group: "workspace.devfile.io", Version: "v1alpha2", Kind: kind}, meta.RESTScopeNamespace)
+	installed, err = devWorkspaceInstalled(mapper)
+	if err != nil || !installed {
+		t.Errorf("devWorkspaceInstalled() = %v, %v, want true with the CRDs", installed, err)
+	}
+}
+
+func Test_nativePod(t *testing.T) {
+	uid := int64(1001)
+	o := DebugIDEOptions{
+		targetPodName:  "outyet",
+		targetPod:      &corev1.Pod{Spec: corev1.PodSpec{NodeName: "worker-1", SecurityContext: &corev1.PodSecurityContext{RunAsUser: &uid}}},
+		debugImage:     defaultDebugImage,
+		shareProcesses: true,
+		sameNode:       true,
+		debugLanguage:  debuggerGo,
+		debugContainer: "outyet",
+		gitRepositories: []gitRepository{
+			{remote: "https://github.com/l0rd/outyet.git", revision: "4f3e2a1"},
+		},
+		targetPodContainers: []ContainerInfo{{
+			name:        "outyet",
+			image:       "quay.io/l0rd/outyet",
+			endpoints:   []ContainerEndpoint{{name: "http", targetPort: 8080}},
+			volumes:     []ContainerVolume{{name: "config", path: "/etc/outyet", readOnly: true}},
+			memoryLimit: "128Mi",
+		}},
+		targetPodVolumes: []corev1.Volume{{
+			Name:         "config",
+			VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{}},
+		}},
+		ide: ideDefinition{env: []dwv1alpha2.EnvVarPluginOverride{{Name: cheCodeContributionContainerEnvName, Value: cheCodeContributionContainerEnvValue}}},
+	}
+
+	p, err := nativePod(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "outyet-dw" || p.Labels[backendLabel] != backendNative || p.Labels[sessionNameLabel] != "outyet-dw" {
+		t.Errorf("nativePod() metadata = %s %v", p.Name, p.Labels)
+	}
+	if p.Spec.ShareProcessNamespace == nil || !*p.Spec.ShareProcessNamespace {
+		t.Errorf("nativePod() doesn't share the process namespace")
+	}
+	if p.Spec.Affinity == nil || p.Spec.SecurityContext != nil {
+		t.Errorf("nativePod() affinity = %v, security context = %v", p.Spec.Affinity, p.Spec.SecurityContext)
+	}
+	if cde := p.Spec.Containers[0]; cde.SecurityContext == nil || cde.SecurityContext.RunAsUser == nil || *cde.SecurityContext.RunAsUser != uid {
+		t.Errorf("nativePod() CDE security context = %v, want user %d", cde.SecurityContext, uid)
+	}
+
+	var initContainers []string
+	for _, c := range p.Spec.InitContainers {
+		initContainers = append(initContainers, c.Name)
+	}
+	if want := []string{cheCodeInjectorName, projectCloneName}; !reflect.DeepEqual(initContainers, want) {
+		t.Errorf("nativePod() init containers = %v, want %v", initContainers, want)
+	}
+	if want := []string{"https://github.com/l0rd/outyet.git", "/projects/outyet", "4f3e2a1"}; !reflect.DeepEqual(p.Spec.InitContainers[1].Args, want) {
+		t.Errorf("nativePod() clone args = %v, want %v", p.Spec.InitContainers[1].Args, want)
+	}
+
+	if len(p.Spec.Containers) != 2 {
+		t.Fatalf("nativePod() has %d containers, want 2", len(p.Spec.Containers))
+	}
+	cde, app := p.Spec.Containers[0], p.Spec.Containers[1]
+	if cde.Name != defaultDevContainerName || len(cde.Ports) != 1 || cde.Ports[0].ContainerPort != nativeIDEPort {
+		t.Errorf("nativePod() CDE container = %+v", cde)
+	}
+	if cde.SecurityContext == nil || cde.SecurityContext.Capabilities.Add[0] != sysPtraceCapability {
+		t.Errorf("nativePod() CDE container doesn't have the %s capability", sysPtraceCapability)
+	}
+	if !reflect.DeepEqual(cde.Env[len(cde.Env)-1], corev1.EnvVar{Name: cheCodeContributionContainerEnvName, Value: cheCodeContributionContainerEnvValue}) {
+		t.Errorf("nativePod() CDE env = %v", cde.Env)
+	}
+	wantMounts := []corev1.VolumeMount{
+		{Name: "config", MountPath: "/etc/outyet", ReadOnly: true},
+		{Name: projectsVolumeName, MountPath: projectsRoot},
+	}
+	if !reflect.DeepEqual(app.VolumeMounts, wantMounts) {
+		t.Errorf("nativePod() container mounts = %v, want %v", app.VolumeMounts, wantMounts)
+	}
+	if q := app.Resources.Limits[corev1.ResourceMemory]; q.Cmp(resource.MustParse("128Mi")) != 0 {
+		t.Errorf("nativePod() container memory limit = %v", q.String())
+	}
+
+	var volumes []string
+	for _, v := range p.Spec.Volumes {
+		volumes = append(volumes, v.Name)
+	}
+	if want := []string{"config", projectsVolumeName, cheCodeVolumeName}; !reflect.DeepEqual(volumes, want) {
+		t.Errorf("nativePod() volumes = %v, want %v", volumes, want)
+	}
+}
+
+func Test_projectCloneContainer(t *testing.T) {
+	c, err := projectCloneContainer(DebugIDEOptions{})
+	if err != nil || c != nil {
+		t.Errorf("projectCloneContainer() = %v, %v, want no container without repositories", c, err)
+	}
+
+	c, err = projectCloneContainer(DebugIDEOptions{
+		sourceProject: "outyet",
+		launchJSON:    []byte("{}"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Args) != 0 || !strings.Contains(c.Command[2], `"/projects/outyet/.vscode/launch.json"`) {
+		t.Errorf("projectCloneContainer() = %v %v, want the launch.json written in the source project", c.Command, c.Args)
+	}
+}
@@ -0,0 +1,264 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	ociSourceLabel   = "org.opencontainers.image.source"
+	ociRevisionLabel = "org.opencontainers.image.revision"
+	ociRefNameLabel  = "org.opencontainers.image.ref.name"
+	// ociLabelsTimeout bounds the time spent getting the labels from the
+	// registry, that is done by default and delays every session
+	ociLabelsTimeout = 5 * time.Second
+	dockerHubAlias   = "docker.io"
+)
+
+// imageSource returns the git repository, and its revision, declared
+// in the OCI labels (or annotations) of an image
+func imageSource(labels map[string]string) (gitRepository, bool) {
+	source := strings.TrimSpace(labels[ociSourceLabel])
+	if source == "" {
+		return gitRepository{}, false
+	}
+	return gitRepository{
+		remote:   source,
+		revision: strings.TrimSpace(labels[ociRevisionLabel]),
+	}, true
+}
+
+// imageContainer returns the name and the image of the container of the
+// Pod whose source gets detected: the --target one or else the first one.
+// The image of a running container is referenced by digest if it's known.
+func imageContainer(pod *corev1.Pod, target string) (string, string) {
+	if len(pod.Spec.Containers) == 0 {
+		return "", ""
+	}
+	c := pod.Spec.Containers[0]
+	for _, ctr := range pod.Spec.Containers {
+		if ctr.Name == target {
+			c = ctr
+		}
+	}
+	image := c.Image
+	for _, s := range pod.Status.ContainerStatuses {
+		if s.Name == c.Name && strings.Contains(s.ImageID, "@sha256:") {
+			image = strings.TrimPrefix(s.ImageID, "docker-pullable://")
+		}
+	}
+	return c.Name, image
+}
+
+// registryImageLabels gets the labels of an image from its registry
+func registryImageLabels(ctx context.Context, image string, keychain authn.Keychain) (map[string]string, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image reference %s: %v", image, err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, ociLabelsTimeout)
+	defer cancel()
+	img, err := remote.Image(ref, remote.WithContext(ctx), remote.WithAuthFromKeychain(keychain))
+	if err != nil {
+		return nil, fmt.Errorf("error getting image %s: %v", image, err)
+	}
+	return imageLabels(img, nil)
+}
+
+// layoutImageLabels gets the labels of an image from an OCI layout. The
+// image is the one whose ref.name annotation matches the reference, or
+// the only one of the layout.
+func layoutImageLabels(path, image string) (map[string]string, error) {
+	p, err := layout.FromPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading OCI layout %s: %v", path, err)
+	}
+	index, err := p.ImageIndex()
+	if err != nil {
+		return nil, fmt.Errorf("error reading OCI layout %s index: %v", path, err)
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("error reading OCI layout %s index: %v", path, err)
+	}
+
+	desc, err := layoutDescriptor(manifest.Manifests, image)
+	if err != nil {
+		return nil, fmt.Errorf("OCI layout %s: %v", path, err)
+	}
+
+	if desc.MediaType.IsIndex() {
+		child, err := index.ImageIndex(desc.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("error reading OCI layout %s index %s: %v", path, desc.Digest, err)
+		}
+		childManifest, err := child.IndexManifest()
+		if err != nil {
+			return nil, fmt.Errorf("error reading OCI layout %s index %s: %v", path, desc.Digest, err)
+		}
+		for _, m := range childManifest.Manifests {
+			if m.MediaType.IsImage() {
+				img, err := child.Image(m.Digest)
+				if err != nil {
+					return nil, fmt.Errorf("error reading OCI layout %s image %s: %v", path, m.Digest, err)
+				}
+				return imageLabels(img, mergeLabels(desc.Annotations, childManifest.Annotations))
+			}
+		}
+		return nil, fmt.Errorf("OCI layout %s: no image found in index %s", path, desc.Digest)
+	}
+
+	img, err := index.Image(desc.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("error reading OCI layout %s image %s: %v", path, desc.Digest, err)
+	}
+	return imageLabels(img, desc.Annotations)
+}
+
+func layoutDescriptor(descriptors []v1.Descriptor, image string) (v1.Descriptor, error) {
+	refs := []string{image}
+	if ref, err := name.ParseReference(image); err == nil {
+		refs = append(refs, ref.Identifier(), ref.Name())
+	}
+	for _, d := range descriptors {
+		for _, r := range refs {
+			if d.Annotations[ociRefNameLabel] == r {
+				return d, nil
+			}
+		}
+	}
+	if len(descriptors) == 1 {
+		return descriptors[0], nil
+	}
+	return v1.Descriptor{}, fmt.Errorf("no image matching %s", image)
+}
+
+// imageLabels merges the annotations of the image descriptor and manifest
+// and the labels of the image config, the latter taking precedence
+func imageLabels(img v1.Image, annotations map[string]string) (map[string]string, error) {
+	labels := mergeLabels(nil, annotations)
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("error reading image manifest: %v", err)
+	}
+	labels = mergeLabels(labels, manifest.Annotations)
+	config, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("error reading image config: %v", err)
+	}
+	return mergeLabels(labels, config.Config.Labels), nil
+}
+
+func mergeLabels(labels, other map[string]string) map[string]string {
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	for k, v := range other {
+		labels[k] = v
+	}
+	return labels
+}
+
+// pullSecretsKeychain is the keychain of the imagePullSecrets of a Pod
+type pullSecretsKeychain map[string]authn.AuthConfig
+
+// newPullSecretsKeychain reads the imagePullSecrets of a Pod. The
+// secrets that cannot be read are skipped.
+func newPullSecretsKeychain(ctx context.Context, clientset kubernetes.Interface, pod *corev1.Pod) pullSecretsKeychain {
+	keychain := pullSecretsKeychain{}
+	for _, ref := range pod.Spec.ImagePullSecrets {
+		s, err := clientset.CoreV1().Secrets(pod.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			continue
+		}
+		keychain.add(s)
+	}
+	return keychain
+}
+
+func (k pullSecretsKeychain) add(s *corev1.Secret) {
+	auths := map[string]authn.AuthConfig{}
+	switch s.Type {
+	case corev1.SecretTypeDockerConfigJson:
+		config := struct {
+			Auths map[string]authn.AuthConfig `json:"auths"`
+		}{}
+		if err := json.Unmarshal(s.Data[corev1.DockerConfigJsonKey], &config); err != nil {
+			return
+		}
+		auths = config.Auths
+	case corev1.SecretTypeDockercfg:
+		if err := json.Unmarshal(s.Data[corev1.DockerConfigKey], &auths); err != nil {
+			return
+		}
+	}
+	for registry, auth := range auths {
+		k[registryHost(registry)] = auth
+	}
+}
+
+// Resolve implements authn.Keychain
+func (k pullSecretsKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	if auth, ok := k[registryHost(target.RegistryStr())]; ok {
+		return authn.FromConfig(auth), nil
+	}
+	return authn.Anonymous, nil
+}
+
+// registryHost normalizes the registry keys of docker configs, that
+// can be URLs such as https://index.docker.io/v1/
+func registryHost(registry string) string {
+	host := registry
+	if u, err := url.Parse(registry); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	host = strings.SplitN(host, "/", 2)[0]
+	if host == name.DefaultRegistry {
+		host = dockerHubAlias
+	}
+	return host
+}
+
+// detectGitRepository resolves the git repository of the target container
+// image from its OCI labels, looking up the image in the --oci-layout if
+// set or else in its registry, that is skipped with --dry-run=client
+func (o *DebugIDEOptions) detectGitRepository(ctx context.Context) (gitRepository, bool, error) {
+	container, image := imageContainer(o.targetPod, o.targetContainer)
+	if image == "" {
+		return gitRepository{}, false, nil
+	}
+
+	var labels map[string]string
+	var err error
+	switch {
+	case o.ociLayout != "":
+		labels, err = layoutImageLabels(o.ociLayout, image)
+	case o.dryRun == dryRunClient:
+		fmt.Fprintf(o.ErrOut, "🔎 the labels of container %s image aren't read from the registry with --dry-run=client, "+
+			"use --git-repository or --oci-layout\n", container)
+		return gitRepository{}, false, nil
+	default:
+		keychain := authn.NewMultiKeychain(
+			newPullSecretsKeychain(ctx, o.clients.clientset, o.targetPod),
+			authn.DefaultKeychain)
+		labels, err = o.imageLabels(ctx, image, keychain)
+	}
+	if err != nil {
+		return gitRepository{}, false, fmt.Errorf("cannot read the labels of container %s image: %v", container, err)
+	}
+	repo, ok := imageSource(labels)
+	return repo, ok, nil
+}
@@ -0,0 +1,177 @@
+package pkg
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_imageContainer(t *testing.T) {
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "outyet", Image: "quay.io/l0rd/outyet:latest"},
+				{Name: "proxy", Image: "quay.io/l0rd/proxy:latest"},
+			},
+		},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "outyet", ImageID: "quay.io/l0rd/outyet@sha256:4f3e2a1"},
+			},
+		},
+	}
+	tests := []struct {
+		name          string
+		target        string
+		wantContainer string
+		wantImage     string
+	}{
+		{name: "first container by digest", wantContainer: "outyet", wantImage: "quay.io/l0rd/outyet@sha256:4f3e2a1"},
+		{name: "target container", target: "proxy", wantContainer: "proxy", wantImage: "quay.io/l0rd/proxy:latest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			container, image := imageContainer(pod, tt.target)
+			if container != tt.wantContainer || image != tt.wantImage {
+				t.Errorf("imageContainer() = %s, %s, want %s, %s", container, image, tt.wantContainer, tt.wantImage)
+			}
+		})
+	}
+}
+
+func Test_layoutImageLabels(t *testing.T) {
+	img, err := random.Image(64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Config.Labels = map[string]string{
+		ociSourceLabel:   "https://github.com/l0rd/outyet",
+		ociRevisionLabel: "4f3e2a1",
+	}
+	img, err = mutate.ConfigFile(img, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := random.Image(64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	p, err := layout.Write(dir, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AppendImage(img, layout.WithAnnotations(map[string]string{ociRefNameLabel: "v1.2.0"})); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AppendImage(other, layout.WithAnnotations(map[string]string{ociRefNameLabel: "v1.1.0"})); err != nil {
+		t.Fatal(err)
+	}
+
+	labels, err := layoutImageLabels(dir, "quay.io/l0rd/outyet:v1.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, ok := imageSource(labels)
+	want := gitRepository{remote: "https://github.com/l0rd/outyet", revision: "4f3e2a1"}
+	if !ok || repo != want {
+		t.Errorf("imageSource() = %v, %v, want %v", repo, ok, want)
+	}
+
+	if _, err := layoutImageLabels(dir, "quay.io/l0rd/outyet:v2"); err == nil {
+		t.Errorf("layoutImageLabels() expected an error for an image that isn't in the layout")
+	}
+}
+
+func Test_pullSecretsKeychain(t *testing.T) {
+	keychain := pullSecretsKeychain{}
+	keychain.add(&corev1.Secret{
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: []byte(`{"auths":{"https://index.docker.io/v1/":{"auth":"bWFyaW86czNjcjN0"},"quay.io":{"username":"l0rd","password":"t0k3n"}}}`),
+		},
+	})
+	tests := []struct {
+		name  string
+		image string
+		want  authn.AuthConfig
+	}{
+		{name: "docker hub", image: "l0rd/outyet", want: authn.AuthConfig{Username: "mario", Password: "s3cr3t"}},
+		{name: "quay", image: "quay.io/l0rd/outyet", want: authn.AuthConfig{Username: "l0rd", Password: "t0k3n"}},
+		{name: "anonymous", image: "ghcr.io/l0rd/outyet"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := name.ParseReference(tt.image)
+			if err != nil {
+				t.Fatal(err)
+			}
+			auth, err := keychain.Resolve(ref.Context())
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := authn.Authorization(context.Background(), auth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got.Auth = ""
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Resolve() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_imageSource(t *testing.T) {
+	if _, ok := imageSource(map[string]string{ociRevisionLabel: "4f3e2a1"}); ok {
+		t.Errorf("imageSource() found a source without the %s label", ociSourceLabel)
+	}
+}
+
+// The registry isn't looked up with --dry-run=client
+func Test_detectGitRepository(t *testing.T) {
+	for _, dryRun := range []string{dryRunNone, dryRunClient} {
+		t.Run(dryRun, func(t *testing.T) {
+			streams, _, _, errOut := genericiooptions.NewTestIOStreams()
+			o := NewDebugIDEOptions(streams)
+			o.dryRun = dryRun
+			o.targetPod = &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "outyet", Image: "quay.io/l0rd/outyet"}}}}
+			o.clients = &kubeClients{namespace: "dev", clientset: fake.NewClientset()}
+			looked := false
+			o.imageLabels = func(context.Context, string, authn.Keychain) (map[string]string, error) {
+				looked = true
+				return map[string]string{ociSourceLabel: "https://github.com/l0rd/outyet"}, nil
+			}
+
+			repo, found, err := o.detectGitRepository(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dryRun == dryRunClient {
+				if looked || found || !strings.Contains(errOut.String(), "--dry-run=client") {
+					t.Errorf("detectGitRepository() looked up the registry with --dry-run=client: %v %q", repo, errOut.String())
+				}
+				return
+			}
+			if !looked || !found || repo.remote != "https://github.com/l0rd/outyet" {
+				t.Errorf("detectGitRepository() = %v, %v", repo, found)
+			}
+		})
+	}
+}
@@ -1,18 +1,35 @@
 package pkg
 
+import (
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// serviceAccountMountPath is where the service account token is mounted.
+// The copy gets its own token and the original mount is skipped.
+const serviceAccountMountPath = "/var/run/secrets/kubernetes.io/serviceaccount"
+
 type ContainerEndpoint struct {
 	name       string
 	targetPort int
+	// protocol is the transport protocol of the port, TCP if not set
+	protocol corev1.Protocol
 }
 
 type ContainerEnv struct {
-	name  string
-	value string
+	name      string
+	value     string
+	valueFrom *corev1.EnvVarSource
 }
 
 type ContainerVolume struct {
-	name string
-	path string
+	name     string
+	path     string
+	subPath  string
+	readOnly bool
+	// ephemeral is true when the volume is an emptyDir
+	ephemeral bool
 }
 
 type ContainerInfo struct {
@@ -20,11 +37,156 @@ type ContainerInfo struct {
 	image         string
 	command       []string
 	args          []string
+	workingDir    string
 	env           []ContainerEnv
+	envFrom       []corev1.EnvFromSource
 	endpoints     []ContainerEndpoint
 	volumes       []ContainerVolume
 	memoryRequest string
 	memoryLimit   string
 	cpuRequest    string
 	cpuLimit      string
+
+	livenessProbe  *corev1.Probe
+	readinessProbe *corev1.Probe
+	startupProbe   *corev1.Probe
+	lifecycle      *corev1.Lifecycle
+}
+
+// podContainers returns the information about the containers of
+// a Pod required to copy them in a DevWorkspace. With sidecars, the
+// native sidecars (the init containers always restarted) are included
+// first, as they start before the other containers.
+func podContainers(pod *corev1.Pod, sidecars bool) []ContainerInfo {
+	volumes := podVolumesByName(pod)
+	containers := make([]ContainerInfo, 0, len(pod.Spec.Containers))
+	if sidecars {
+		for _, c := range pod.Spec.InitContainers {
+			if isSidecar(c) {
+				containers = append(containers, containerInfo(c, volumes))
+			}
+		}
+	}
+	for _, c := range pod.Spec.Containers {
+		containers = append(containers, containerInfo(c, volumes))
+	}
+	return containers
+}
+
+// podInitContainers returns the init containers of a Pod that run to
+// completion, in their execution order, excluding the native sidecars
+func podInitContainers(pod *corev1.Pod) []ContainerInfo {
+	volumes := podVolumesByName(pod)
+	var containers []ContainerInfo
+	for _, c := range pod.Spec.InitContainers {
+		if !isSidecar(c) {
+			containers = append(containers, containerInfo(c, volumes))
+		}
+	}
+	return containers
+}
+
+// isSidecar is true for the native sidecars (Kubernetes 1.29+), the init
+// containers that keep running alongside the containers of the Pod
+func isSidecar(c corev1.Container) bool {
+	return c.RestartPolicy != nil && *c.RestartPolicy == corev1.ContainerRestartPolicyAlways
+}
+
+func podVolumesByName(pod *corev1.Pod) map[string]corev1.Volume {
+	volumes := make(map[string]corev1.Volume, len(pod.Spec.Volumes))
+	for _, v := range pod.Spec.Volumes {
+		volumes[v.Name] = v
+	}
+	return volumes
+}
+
+func containerInfo(c corev1.Container, volumes map[string]corev1.Volume) ContainerInfo {
+	info := ContainerInfo{
+		name:       c.Name,
+		image:      c.Image,
+		command:    c.Command,
+		args:       c.Args,
+		workingDir: c.WorkingDir,
+		envFrom:    c.EnvFrom,
+
+		livenessProbe:  c.LivenessProbe,
+		readinessProbe: c.ReadinessProbe,
+		startupProbe:   c.StartupProbe,
+		lifecycle:      c.Lifecycle,
+	}
+
+	if q, ok := c.Resources.Limits[corev1.ResourceMemory]; ok {
+		info.memoryLimit = q.String()
+	}
+	if q, ok := c.Resources.Requests[corev1.ResourceMemory]; ok {
+		info.memoryRequest = q.String()
+	}
+	if q, ok := c.Resources.Limits[corev1.ResourceCPU]; ok {
+		info.cpuLimit = q.String()
+	}
+	if q, ok := c.Resources.Requests[corev1.ResourceCPU]; ok {
+		info.cpuRequest = q.String()
+	}
+
+	for _, e := range c.Env {
+		info.env = append(info.env, ContainerEnv{
+			name:      e.Name,
+			value:     e.Value,
+			valueFrom: e.ValueFrom,
+		})
+	}
+
+	for _, p := range c.Ports {
+		portName := p.Name
+		portNumber := int(p.ContainerPort)
+		if portName == "" {
+			portName = "port" + strconv.Itoa(portNumber)
+		}
+		protocol := p.Protocol
+		if protocol == "" {
+			protocol = corev1.ProtocolTCP
+		}
+		info.endpoints = append(info.endpoints, ContainerEndpoint{
+			name:       portName,
+			targetPort: portNumber,
+			protocol:   protocol,
+		})
+	}
+
+	for _, m := range c.VolumeMounts {
+		if m.MountPath == serviceAccountMountPath {
+			continue
+		}
+		v, ok := volumes[m.Name]
+		if !ok {
+			continue
+		}
+		info.volumes = append(info.volumes, ContainerVolume{
+			name:      m.Name,
+			path:      m.MountPath,
+			subPath:   m.SubPath,
+			readOnly:  m.ReadOnly,
+			ephemeral: v.EmptyDir != nil,
+		})
+	}
+
+	return info
+}
+
+// podVolumes returns the volumes of a Pod that are mounted by
+// the containers that get copied, skipping the service account one
+func podVolumes(pod *corev1.Pod, containers []ContainerInfo) []corev1.Volume {
+	mounted := map[string]bool{}
+	for _, c := range containers {
+		for _, v := range c.volumes {
+			mounted[v.name] = true
+		}
+	}
+	volumes := make([]corev1.Volume, 0, len(mounted))
+	for _, v := range pod.Spec.Volumes {
+		if mounted[v.Name] {
+			volumes = append(volumes, v)
+		}
+	}
+	return volumes
 }
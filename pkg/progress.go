@@ -0,0 +1,304 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	progressIndent       = "   "
+	maxSummaryWarnings   = 5
+	eventTimestampMargin = 2 * time.Second
+)
+
+// progressReporter prints what happens while waiting for a debugging
+// session to start: the DevWorkspace status messages, the containers
+// state transitions and the related Events. It keeps the last known
+// state to print a summary if the session doesn't start.
+type progressReporter struct {
+	mu sync.Mutex
+
+	out   io.Writer
+	since time.Time
+	// pending is true when a "waiting..." line has been printed
+	// and the progress lines must start on a new line
+	pending bool
+	// closed is true when the wait is over and nothing is printed anymore
+	closed bool
+
+	message         string
+	containerStates map[string]string
+	seenEvents      map[string]bool
+	warnings        []string
+}
+
+func newProgressReporter(out io.Writer, since time.Time) *progressReporter {
+	return &progressReporter{
+		out:             out,
+		since:           since,
+		containerStates: map[string]string{},
+		seenEvents:      map[string]bool{},
+	}
+}
+
+// start prints the description of a wait step
+func (r *progressReporter) start(format string, a ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintf(r.out, format, a...)
+	r.pending = true
+}
+
+// done prints the completion of a wait step
+func (r *progressReporter) done(format string, a ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.pending {
+		fmt.Fprint(r.out, progressIndent)
+	}
+	fmt.Fprintf(r.out, format, a...)
+	r.pending = false
+}
+
+// fail terminates a wait step that failed
+func (r *progressReporter) fail() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pending {
+		fmt.Fprintln(r.out)
+	}
+	r.pending = false
+}
+
+// close stops the reporting, the Events received afterwards are ignored
+func (r *progressReporter) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+}
+
+func (r *progressReporter) printf(format string, a ...interface{}) {
+	if r.closed {
+		return
+	}
+	if r.pending {
+		fmt.Fprintln(r.out)
+		r.pending = false
+	}
+	fmt.Fprintf(r.out, progressIndent+format+"\n", a...)
+}
+
+// devWorkspace reports the changes of the DevWorkspace status message
+func (r *progressReporter) devWorkspace(s session) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s.message == "" || s.message == r.message {
+		return
+	}
+	r.message = s.message
+	r.printf("devworkspace %s: %s", s.name, s.message)
+}
+
+// deployment reports the Deployment conditions that explain why it's not available
+func (r *progressReporter) deployment(d *appv1.Deployment) {
+	if r == nil {
+		return
+	}
+	for _, c := range d.Status.Conditions {
+		if c.Type == appv1.DeploymentReplicaFailure && c.Status == corev1.ConditionTrue {
+			r.warning("deployment/"+d.Name, c.Reason, c.Message)
+		}
+	}
+}
+
+// pod reports the state transitions of the Pod containers. If containers
+// is not empty, only the containers with those names are reported.
+func (r *progressReporter) pod(p *corev1.Pod, containers ...string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	statuses := make([]corev1.ContainerStatus, 0,
+		len(p.Status.InitContainerStatuses)+len(p.Status.ContainerStatuses)+len(p.Status.EphemeralContainerStatuses))
+	statuses = append(statuses, p.Status.InitContainerStatuses...)
+	statuses = append(statuses, p.Status.ContainerStatuses...)
+	statuses = append(statuses, p.Status.EphemeralContainerStatuses...)
+	for _, cs := range statuses {
+		if len(containers) > 0 && !contains(containers, cs.Name) {
+			continue
+		}
+		state := containerState(cs.State)
+		if state == "" || r.containerStates[cs.Name] == state {
+			continue
+		}
+		r.containerStates[cs.Name] = state
+		r.printf("container %s: %s", cs.Name, state)
+	}
+}
+
+// event reports a Warning Event or a Normal Event about image pulling
+func (r *progressReporter) event(e *corev1.Event) {
+	if r == nil {
+		return
+	}
+	if eventTime(e).Add(eventTimestampMargin).Before(r.since) {
+		return
+	}
+	object := strings.ToLower(e.InvolvedObject.Kind) + "/" + e.InvolvedObject.Name
+	if e.Type == corev1.EventTypeWarning {
+		r.warning(object, e.Reason, e.Message)
+		return
+	}
+	if e.Reason == "Pulling" || e.Reason == "Pulled" {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		key := object + e.Reason + e.Message
+		if r.seenEvents[key] {
+			return
+		}
+		r.seenEvents[key] = true
+		r.printf("%s: %s", object, e.Message)
+	}
+}
+
+func (r *progressReporter) warning(object, reason, message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := object + reason + message
+	if r.seenEvents[key] {
+		return
+	}
+	r.seenEvents[key] = true
+	w := fmt.Sprintf("⚠️ %s %s: %s", object, reason, message)
+	r.warnings = append(r.warnings, w)
+	r.printf("%s", w)
+}
+
+// summary prints the last known state of the session
+func (r *progressReporter) summary(w io.Writer) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.message == "" && len(r.containerStates) == 0 && len(r.warnings) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "❌ the debugging session didn't start, last known status:\n")
+	if r.message != "" {
+		fmt.Fprintf(w, "%sdevworkspace: %s\n", progressIndent, r.message)
+	}
+	names := make([]string, 0, len(r.containerStates))
+	for name := range r.containerStates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "%scontainer %s: %s\n", progressIndent, name, r.containerStates[name])
+	}
+	warnings := r.warnings
+	if len(warnings) > maxSummaryWarnings {
+		warnings = warnings[len(warnings)-maxSummaryWarnings:]
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(w, "%s%s\n", progressIndent, warning)
+	}
+}
+
+// watchEvents reports the Events in namespace for which relevant
+// returns true until ctx is done
+func (r *progressReporter) watchEvents(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	relevant func(e *corev1.Event) bool,
+) {
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return clientset.CoreV1().Events(namespace).List(ctx, options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return clientset.CoreV1().Events(namespace).Watch(ctx, options)
+		},
+	}
+	_, _ = waitFor(ctx, lw, &corev1.Event{}, nil, func(event watch.Event) (bool, error) {
+		if e, ok := event.Object.(*corev1.Event); ok && event.Type != watch.Deleted && relevant(e) {
+			r.event(e)
+		}
+		return false, nil
+	})
+}
+
+// sessionEventFilter selects the Events of a DevWorkspace and of the objects
+// created for it, whose names start with the DevWorkspace id
+func sessionEventFilter(dwName, dwID string) func(e *corev1.Event) bool {
+	return func(e *corev1.Event) bool {
+		name := e.InvolvedObject.Name
+		return name == dwName || (dwID != "" && strings.HasPrefix(name, dwID))
+	}
+}
+
+// ephemeralEventFilter selects the Events of an ephemeral container
+func ephemeralEventFilter(podName, container string) func(e *corev1.Event) bool {
+	return func(e *corev1.Event) bool {
+		return e.InvolvedObject.Kind == "Pod" && e.InvolvedObject.Name == podName &&
+			strings.Contains(e.InvolvedObject.FieldPath, "{"+container+"}")
+	}
+}
+
+func containerState(s corev1.ContainerState) string {
+	switch {
+	case s.Waiting != nil:
+		if s.Waiting.Message != "" {
+			return fmt.Sprintf("waiting (%s): %s", s.Waiting.Reason, s.Waiting.Message)
+		}
+		return fmt.Sprintf("waiting (%s)", s.Waiting.Reason)
+	case s.Running != nil:
+		return "running"
+	case s.Terminated != nil:
+		if s.Terminated.Message != "" {
+			return fmt.Sprintf("terminated (%s, exit code %d): %s", s.Terminated.Reason, s.Terminated.ExitCode, s.Terminated.Message)
+		}
+		return fmt.Sprintf("terminated (%s, exit code %d)", s.Terminated.Reason, s.Terminated.ExitCode)
+	}
+	return ""
+}
+
+func eventTime(e *corev1.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	default:
+		return e.CreationTimestamp.Time
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
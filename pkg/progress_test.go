@@ -0,0 +1,139 @@
+package pkg
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_progressReporter(t *testing.T) {
+	since := time.Date(2024, 12, 1, 10, 0, 0, 0, time.UTC)
+	waiting := func(reason, message string) corev1.ContainerState {
+		return corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason, Message: message}}
+	}
+	pod := func(states ...corev1.ContainerState) *corev1.Pod {
+		p := &corev1.Pod{}
+		for _, s := range states {
+			p.Status.ContainerStatuses = append(p.Status.ContainerStatuses, corev1.ContainerStatus{Name: "tools", State: s})
+		}
+		return p
+	}
+	event := func(eventType, reason, message string, at time.Time) *corev1.Event {
+		return &corev1.Event{
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "workspace1234-6f8c9-xk2p"},
+			Type:           eventType,
+			Reason:         reason,
+			Message:        message,
+			LastTimestamp:  metav1.NewTime(at),
+		}
+	}
+
+	tests := []struct {
+		name        string
+		report      func(r *progressReporter)
+		wantOut     string
+		wantSummary string
+	}{
+		{
+			name: "step without progress",
+			report: func(r *progressReporter) {
+				r.start("waiting...")
+				r.done("done\n")
+			},
+			wantOut: "waiting...done\n",
+		},
+		{
+			name: "container transitions and messages are reported once",
+			report: func(r *progressReporter) {
+				r.start("waiting...")
+				r.devWorkspace(session{name: "outyet-dw", message: "Waiting for workspace deployment"})
+				r.devWorkspace(session{name: "outyet-dw", message: "Waiting for workspace deployment"})
+				r.pod(pod(waiting("ContainerCreating", "")))
+				r.pod(pod(waiting("ContainerCreating", "")))
+				r.pod(pod(corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}))
+				r.done("done\n")
+			},
+			wantOut: `waiting...
+   devworkspace outyet-dw: Waiting for workspace deployment
+   container tools: waiting (ContainerCreating)
+   container tools: running
+   done
+`,
+			wantSummary: `❌ the debugging session didn't start, last known status:
+   devworkspace: Waiting for workspace deployment
+   container tools: running
+`,
+		},
+		{
+			name: "warning events and failure summary",
+			report: func(r *progressReporter) {
+				r.start("waiting...")
+				r.event(event(corev1.EventTypeWarning, "FailedScheduling", "old event", since.Add(-time.Hour)))
+				r.event(event(corev1.EventTypeNormal, "Scheduled", "Successfully assigned", since))
+				r.event(event(corev1.EventTypeNormal, "Pulling", `Pulling image "quay.io/l0rd/outyet"`, since))
+				r.event(event(corev1.EventTypeWarning, "Failed", "ErrImagePull", since))
+				r.event(event(corev1.EventTypeWarning, "Failed", "ErrImagePull", since.Add(time.Second)))
+				r.pod(pod(waiting("ImagePullBackOff", `Back-off pulling image "quay.io/l0rd/outyet"`)))
+				r.fail()
+			},
+			wantOut: `waiting...
+   pod/workspace1234-6f8c9-xk2p: Pulling image "quay.io/l0rd/outyet"
+   ⚠️ pod/workspace1234-6f8c9-xk2p Failed: ErrImagePull
+   container tools: waiting (ImagePullBackOff): Back-off pulling image "quay.io/l0rd/outyet"
+`,
+			wantSummary: `❌ the debugging session didn't start, last known status:
+   container tools: waiting (ImagePullBackOff): Back-off pulling image "quay.io/l0rd/outyet"
+   ⚠️ pod/workspace1234-6f8c9-xk2p Failed: ErrImagePull
+`,
+		},
+		{
+			name: "nothing is printed once closed",
+			report: func(r *progressReporter) {
+				r.close()
+				r.event(event(corev1.EventTypeWarning, "BackOff", "Back-off restarting failed container", since))
+			},
+			wantSummary: `❌ the debugging session didn't start, last known status:
+   ⚠️ pod/workspace1234-6f8c9-xk2p BackOff: Back-off restarting failed container
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			r := newProgressReporter(out, since)
+			tt.report(r)
+			if out.String() != tt.wantOut {
+				t.Errorf("progressReporter output got:\n%s\nwant:\n%s", out.String(), tt.wantOut)
+			}
+			summary := &bytes.Buffer{}
+			r.summary(summary)
+			if summary.String() != tt.wantSummary {
+				t.Errorf("summary() got:\n%s\nwant:\n%s", summary.String(), tt.wantSummary)
+			}
+		})
+	}
+}
+
+func Test_sessionEventFilter(t *testing.T) {
+	filter := sessionEventFilter("outyet-dw", "workspace1234")
+	tests := []struct {
+		name   string
+		object string
+		want   bool
+	}{
+		{name: "devworkspace", object: "outyet-dw", want: true},
+		{name: "pod", object: "workspace1234-6f8c9-xk2p", want: true},
+		{name: "other", object: "outyet-7d9f-abcd", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &corev1.Event{InvolvedObject: corev1.ObjectReference{Name: tt.object}}
+			if got := filter(e); got != tt.want {
+				t.Errorf("sessionEventFilter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package pkg
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	dwv1alpha2 "github.com/devfile/api/v2/pkg/apis/workspaces/v1alpha2"
+	"sigs.k8s.io/yaml"
+)
+
+// renderYAML marshals obj to YAML, with sorted keys and without the null
+// fields, the empty lists and the status. The empty objects are kept as
+// they can be meaningful (e.g. emptyDir: {}).
+func renderYAML(obj interface{}) ([]byte, error) {
+	m, err := pruned(obj)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(m)
+}
+
+// renderJSON is the JSON equivalent of renderYAML, indented as kubectl does
+func renderJSON(obj interface{}) ([]byte, error) {
+	m, err := pruned(obj)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.MarshalIndent(m, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// pruned returns obj as a map without the null fields, the empty
+// lists and the status
+func pruned(obj interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	delete(m, "status")
+	prune(m)
+	return m, nil
+}
+
+// prune removes the null values and the empty lists of v
+func prune(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			e = prune(e)
+			if isEmptyValue(e) {
+				delete(v, k)
+				continue
+			}
+			v[k] = e
+		}
+		return v
+	case []interface{}:
+		pruned := make([]interface{}, 0, len(v))
+		for _, e := range v {
+			if e = prune(e); e != nil {
+				pruned = append(pruned, e)
+			}
+		}
+		return pruned
+	}
+	return v
+}
+
+func isEmptyValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	l, ok := v.([]interface{})
+	return ok && len(l) == 0
+}
+
+// normalize sorts the parts of the DevWorkspace whose order doesn't matter:
+// the env variables and the volume components, that follow the container
+// ones. The order of the containers is the one of the Pod.
+func normalize(dw *dwv1alpha2.DevWorkspace) {
+	components := dw.Spec.Template.Components
+	for _, c := range components {
+		if c.Container != nil {
+			sortDevfileEnv(c.Container.Env)
+		}
+	}
+	sort.SliceStable(components, func(i, j int) bool {
+		vi, vj := components[i].Volume != nil, components[j].Volume != nil
+		if vi != vj {
+			return vj
+		}
+		return vi && components[i].Name < components[j].Name
+	})
+
+	for _, c := range dw.Spec.Contributions {
+		for _, o := range c.PluginOverrides.Components {
+			if o.Container != nil {
+				sortOverrideEnv(o.Container.Env)
+			}
+		}
+	}
+}
+
+// The env variables are sorted by name unless a value references another
+// variable, $(NAME), as the references are expanded in declaration order.
+func sortDevfileEnv(env []dwv1alpha2.EnvVar) {
+	if referencesEnv(len(env), func(i int) string { return env[i].Value }) {
+		return
+	}
+	sort.SliceStable(env, func(i, j int) bool { return env[i].Name < env[j].Name })
+}
+
+func sortOverrideEnv(env []dwv1alpha2.EnvVarPluginOverride) {
+	if referencesEnv(len(env), func(i int) string { return env[i].Value }) {
+		return
+	}
+	sort.SliceStable(env, func(i, j int) bool { return env[i].Name < env[j].Name })
+}
+
+func referencesEnv(n int, value func(i int) string) bool {
+	for i := 0; i < n; i++ {
+		if strings.Contains(value(i), "$(") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package pkg
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"sigs.k8s.io/yaml"
+)
+
+// Run go test ./pkg -run Test_render -update to regenerate the golden files
+var update = flag.Bool("update", false, "update the golden files in testdata/render")
+
+func Test_render(t *testing.T) {
+	tests := []struct {
+		name string
+		pod  string
+		opts func(o *DebugIDEOptions)
+	}{
+		{
+			name: "single-container",
+			pod:  "single-container",
+			opts: func(o *DebugIDEOptions) {
+				o.gitRepositoryArgs = []string{"https://github.com/l0rd/outyet.git"}
+				o.gitRevision = "4f3e2a1"
+			},
+		},
+		{
+			name: "single-container-debugger",
+			pod:  "single-container",
+			opts: func(o *DebugIDEOptions) {
+				o.gitRepositoryArgs = []string{"https://github.com/l0rd/outyet.git"}
+				o.debugger = debuggerGo
+				o.sameNode = true
+				o.copyToPodName = "outyet-debug"
+			},
+		},
+		{
+			name: "ssh",
+			pod:  "single-container",
+			opts: func(o *DebugIDEOptions) {
+				o.ssh = true
+			},
+		},
+		{
+			name: "git-ssh-key",
+			pod:  "single-container",
+			opts: func(o *DebugIDEOptions) {
+				o.gitRepositoryArgs = []string{"https://github.com/l0rd/outyet.git", "git@github.com:l0rd/private.git"}
+				o.gitSSHPrivateKey = []byte("private")
+			},
+		},
+		{name: "multi-container", pod: "multi-container"},
+		{name: "init-containers", pod: "init-containers"},
+		{name: "sidecars", pod: "sidecars"},
+		{
+			name: "skip-init-containers",
+			pod:  "sidecars",
+			opts: func(o *DebugIDEOptions) {
+				o.skipInitContainers = true
+			},
+		},
+		{name: "probes", pod: "probes"},
+		{
+			name: "disable-probes",
+			pod:  "probes",
+			opts: func(o *DebugIDEOptions) {
+				o.disableProbes = true
+			},
+		},
+		{
+			name: "volumes",
+			pod:  "volumes",
+			opts: func(o *DebugIDEOptions) {
+				o.shareProcesses = false
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := renderOptions(t, filepath.Join("testdata", "render", tt.pod+".pod.yaml"), tt.opts)
+			got, err := render(o)
+			if err != nil {
+				t.Fatalf("render() error = %v", err)
+			}
+			again, err := render(o)
+			if err != nil || !bytes.Equal(got, again) {
+				t.Fatalf("render() isn't deterministic")
+			}
+
+			golden := filepath.Join("testdata", "render", tt.name+".golden.yaml")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("render() differs from %s (run with -update if expected):\n%s", golden, got)
+			}
+		})
+	}
+}
+
+// render returns the YAML of the DevWorkspace generated for the options.
+// The output is deterministic: the same Pod and options always render the
+// same bytes, so that changes in what is deployed show up as diffs.
+func render(o DebugIDEOptions) ([]byte, error) {
+	dw, err := generate(o)
+	if err != nil {
+		return nil, err
+	}
+	return renderYAML(&dw)
+}
+
+// renderOptions completes the options the way debug-ide does
+// for the Pod of the file podFile
+func renderOptions(t *testing.T, podFile string, opts func(o *DebugIDEOptions)) DebugIDEOptions {
+	t.Helper()
+	b, err := os.ReadFile(podFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := &corev1.Pod{}
+	if err := yaml.UnmarshalStrict(b, pod); err != nil {
+		t.Fatalf("invalid %s: %v", podFile, err)
+	}
+
+	o := NewDebugIDEOptions(genericiooptions.NewTestIOStreamsDiscard())
+	o.targetPod = pod
+	o.targetPodName = pod.Name
+	o.debugImage = defaultDebugImage
+	if opts != nil {
+		opts(o)
+	}
+	o.ide, err = resolveIDE(defaultIdeReference, defaultPluginRegistryURL, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.gitRepositories, err = parseGitRepositories(o.gitRepositoryArgs, o.gitRevision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.completeTargetContainers(pod)
+	if len(o.debugger) > 0 {
+		if err := o.completeDebugger(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return *o
+}
@@ -0,0 +1,115 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+
+	dwv1alpha2 "github.com/devfile/api/v2/pkg/apis/workspaces/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	podSecurityEnforceLabel = "pod-security.kubernetes.io/enforce"
+	podSecurityPrivileged   = "privileged"
+	podSecurityBaseline     = "baseline"
+	podSecurityRestricted   = "restricted"
+	sysPtraceCapability     = corev1.Capability("SYS_PTRACE")
+)
+
+// debuggerNeedsPtrace returns true for the debuggers that attach to the
+// process from the CDE container, through the shared process namespace,
+// rather than listening on a socket
+func debuggerNeedsPtrace(language string) bool {
+	return language == debuggerGo
+}
+
+// targetRunAsUser returns the user that the container to debug runs as, if
+// it's declared in the container or in the Pod security context
+func targetRunAsUser(pod *corev1.Pod, container string) *int64 {
+	for _, c := range pod.Spec.Containers {
+		if c.Name == container && c.SecurityContext != nil && c.SecurityContext.RunAsUser != nil {
+			return c.SecurityContext.RunAsUser
+		}
+	}
+	if pod.Spec.SecurityContext != nil {
+		return pod.Spec.SecurityContext.RunAsUser
+	}
+	return nil
+}
+
+// ptraceSecurityContext is the security context of the CDE container
+// that allows the debugger to attach to the processes of the Pod. The
+// debugger must run as the same user as the process, runAsUser if set:
+// only the CDE container runs as this user, the copied containers keep
+// their own.
+func ptraceSecurityContext(runAsUser *int64) *corev1.SecurityContext {
+	return &corev1.SecurityContext{
+		Capabilities: &corev1.Capabilities{
+			Add: []corev1.Capability{sysPtraceCapability},
+		},
+		RunAsUser: runAsUser,
+	}
+}
+
+// addPtraceOverrides adds the SYS_PTRACE capability, and the user of the
+// container to debug, to the CDE container through the container-overrides
+func addPtraceOverrides(c *dwv1alpha2.Component, runAsUser *int64) error {
+	return addContainerOverrides(c, map[string]interface{}{
+		"securityContext": ptraceSecurityContext(runAsUser),
+	})
+}
+
+// debuggerRunAsUser returns the user the CDE container runs as for the
+// debuggers that attach to the process, nil if it doesn't matter
+func (o DebugIDEOptions) debuggerRunAsUser() *int64 {
+	if !debuggerNeedsPtrace(o.debugLanguage) || o.targetPod == nil {
+		return nil
+	}
+	return targetRunAsUser(o.targetPod, o.debugContainer)
+}
+
+// podSecurityLevel returns the Pod Security Admission level enforced in
+// the namespace, empty if it isn't set or the namespace cannot be read
+func podSecurityLevel(ctx context.Context, clientset kubernetes.Interface, namespace string) (string, error) {
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if k8serrors.IsForbidden(err) || k8serrors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error getting namespace %s: %v", namespace, err)
+	}
+	return ns.Labels[podSecurityEnforceLabel], nil
+}
+
+// checkPodSecurity returns an error if the Pod Security Standard enforced
+// in the namespace rejects what the debugger of the language needs. Only
+// the privileged level allows the SYS_PTRACE capability.
+func checkPodSecurity(namespace, level, language string) error {
+	if !debuggerNeedsPtrace(language) {
+		return nil
+	}
+	switch level {
+	case podSecurityBaseline, podSecurityRestricted:
+		return fmt.Errorf("the %s debugger cannot attach to the application: it needs the %s capability "+
+			"that the %q Pod Security Standard enforced in namespace %s forbids (label %s). "+
+			"Use a namespace with the %q level",
+			language, sysPtraceCapability, level, namespace, podSecurityEnforceLabel, podSecurityPrivileged)
+	}
+	return nil
+}
+
+// checkDebuggerPodSecurity is the pre-flight check of the Pod Security
+// Admission level of the session namespace
+func (o *DebugIDEOptions) checkDebuggerPodSecurity(ctx context.Context) error {
+	if !debuggerNeedsPtrace(o.debugLanguage) {
+		return nil
+	}
+	level, err := podSecurityLevel(ctx, o.clients.clientset, o.clients.namespace)
+	if err != nil {
+		return err
+	}
+	return checkPodSecurity(o.clients.namespace, level, o.debugLanguage)
+}
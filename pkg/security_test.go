@@ -0,0 +1,79 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func Test_checkPodSecurity(t *testing.T) {
+	tests := []struct {
+		name     string
+		level    string
+		language string
+		wantErr  bool
+	}{
+		{name: "no level", language: debuggerGo},
+		{name: "privileged", level: podSecurityPrivileged, language: debuggerGo},
+		{name: "baseline", level: podSecurityBaseline, language: debuggerGo, wantErr: true},
+		{name: "restricted", level: podSecurityRestricted, language: debuggerGo, wantErr: true},
+		{name: "socket debugger", level: podSecurityRestricted, language: debuggerJava},
+		{name: "no debugger", level: podSecurityRestricted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkPodSecurity("dev", tt.level, tt.language); (err != nil) != tt.wantErr {
+				t.Errorf("checkPodSecurity() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_targetRunAsUser(t *testing.T) {
+	podUID, containerUID := int64(1000), int64(1001)
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			SecurityContext: &corev1.PodSecurityContext{RunAsUser: &podUID},
+			Containers: []corev1.Container{
+				{Name: "outyet", SecurityContext: &corev1.SecurityContext{RunAsUser: &containerUID}},
+				{Name: "proxy"},
+			},
+		},
+	}
+	if got := targetRunAsUser(pod, "outyet"); got == nil || *got != containerUID {
+		t.Errorf("targetRunAsUser() = %v, want the container user %d", got, containerUID)
+	}
+	if got := targetRunAsUser(pod, "proxy"); got == nil || *got != podUID {
+		t.Errorf("targetRunAsUser() = %v, want the Pod user %d", got, podUID)
+	}
+	if got := targetRunAsUser(&corev1.Pod{}, "outyet"); got != nil {
+		t.Errorf("targetRunAsUser() = %d, want nil", *got)
+	}
+}
+
+func Test_addPtraceOverrides(t *testing.T) {
+	c := cdeContainer(defaultDebugImage)
+	resources := corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")}}
+	if err := addContainerOverrides(&c, map[string]interface{}{"resources": resources}); err != nil {
+		t.Fatal(err)
+	}
+	uid := int64(1001)
+	if err := addPtraceOverrides(&c, &uid); err != nil {
+		t.Fatal(err)
+	}
+	overrides := struct {
+		Resources       corev1.ResourceRequirements `json:"resources"`
+		SecurityContext *corev1.SecurityContext     `json:"securityContext"`
+	}{}
+	if err := c.Attributes.GetInto(containerOverridesAttribute, &overrides); err != nil {
+		t.Fatal(err)
+	}
+	if overrides.Resources.Limits.Memory().String() != "1Gi" {
+		t.Errorf("addPtraceOverrides() replaced the resources: %v", overrides.Resources)
+	}
+	if !reflect.DeepEqual(overrides.SecurityContext, ptraceSecurityContext(&uid)) {
+		t.Errorf("addPtraceOverrides() security context = %v, want %v", overrides.SecurityContext, ptraceSecurityContext(&uid))
+	}
+}
@@ -0,0 +1,170 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// Labels and annotations set on the objects created by the plugin. They
+// are used to find the debugging sessions and to describe them.
+const (
+	managedByLabel          = "app.kubernetes.io/managed-by"
+	managedByValue          = "kubectl-debug-ide"
+	targetPodAnnotation     = "debug-ide.devfile.io/target-pod"
+	imageAnnotation         = "debug-ide.devfile.io/image"
+	gitRepositoryAnnotation = "debug-ide.devfile.io/git-repository"
+)
+
+// session is a debugging session, i.e. a DevWorkspace or, with the
+// native backend, a Pod created by the plugin
+type session struct {
+	namespace     string
+	name          string
+	backend       string
+	id            string
+	targetPod     string
+	image         string
+	gitRepository string
+	phase         string
+	message       string
+	url           string
+	started       bool
+	created       time.Time
+}
+
+func sessionLabels() map[string]string {
+	return map[string]string{
+		managedByLabel: managedByValue,
+	}
+}
+
+func sessionAnnotations(o DebugIDEOptions) map[string]string {
+	a := map[string]string{
+		targetPodAnnotation: o.targetPodName,
+		imageAnnotation:     o.debugImage,
+	}
+	if len(o.gitRepositories) > 0 {
+		a[gitRepositoryAnnotation] = gitRepositoriesString(o.gitRepositories)
+	}
+	return a
+}
+
+func sessionSelector() string {
+	return labels.SelectorFromSet(sessionLabels()).String()
+}
+
+func nativeSessionSelector() string {
+	l := sessionLabels()
+	l[backendLabel] = backendNative
+	return labels.SelectorFromSet(l).String()
+}
+
+// devWorkspaceResource returns the GroupVersionResource of the DevWorkspaces
+func devWorkspaceResource(mapper meta.RESTMapper) (schema.GroupVersionResource, error) {
+	return devfileResource(mapper, kind)
+}
+
+// devfileResource returns the GroupVersionResource of a kind
+// of the workspace.devfile.io API group
+func devfileResource(mapper meta.RESTMapper, kind string) (schema.GroupVersionResource, error) {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return schema.GroupVersionResource{}, err
+	}
+	mapping, err := mapper.RESTMapping(schema.GroupKind{Group: gv.Group, Kind: kind}, gv.Version)
+	if err != nil {
+		return schema.GroupVersionResource{}, fmt.Errorf("RESTMapping error: %v", err)
+	}
+	return mapping.Resource, nil
+}
+
+// listSessions returns the DevWorkspaces created by the plugin in namespace
+// (all namespaces if namespace is empty)
+func listSessions(
+	ctx context.Context,
+	dynClient dynamic.Interface,
+	gvr schema.GroupVersionResource,
+	namespace string,
+) (*unstructured.UnstructuredList, error) {
+	list, err := dynClient.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: sessionSelector(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing debugging sessions: %v", err)
+	}
+	return list, nil
+}
+
+// listAllSessions returns the DevWorkspaces, if the DevWorkspace Operator
+// is installed, and the Pods of the native backend created by the plugin
+func listAllSessions(ctx context.Context, clients *kubeClients, namespace string) (*unstructured.UnstructuredList, error) {
+	all := &unstructured.UnstructuredList{}
+	all.SetAPIVersion("v1")
+	all.SetKind("List")
+
+	installed, err := devWorkspaceInstalled(clients.mapper)
+	if err != nil {
+		return nil, err
+	}
+	if installed {
+		gvr, err := devWorkspaceResource(clients.mapper)
+		if err != nil {
+			return nil, err
+		}
+		list, err := listSessions(ctx, clients.dynClient, gvr, namespace)
+		if err != nil {
+			return nil, err
+		}
+		all.Items = append(all.Items, list.Items...)
+	}
+
+	pods, err := clients.dynClient.Resource(podsResource).Namespace(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: nativeSessionSelector(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing debugging sessions: %v", err)
+	}
+	all.Items = append(all.Items, pods.Items...)
+	return all, nil
+}
+
+func sessionFromUnstructured(u unstructured.Unstructured) session {
+	annotations := u.GetAnnotations()
+	if u.GetLabels()[backendLabel] == backendNative {
+		s := session{
+			namespace:     u.GetNamespace(),
+			name:          u.GetName(),
+			backend:       backendNative,
+			targetPod:     annotations[targetPodAnnotation],
+			image:         annotations[imageAnnotation],
+			gitRepository: annotations[gitRepositoryAnnotation],
+			created:       u.GetCreationTimestamp().Time,
+			started:       true,
+		}
+		s.phase, _, _ = unstructured.NestedString(u.Object, "status", "phase")
+		return s
+	}
+	s := session{
+		namespace:     u.GetNamespace(),
+		name:          u.GetName(),
+		backend:       backendDevWorkspace,
+		targetPod:     annotations[targetPodAnnotation],
+		image:         annotations[imageAnnotation],
+		gitRepository: annotations[gitRepositoryAnnotation],
+		created:       u.GetCreationTimestamp().Time,
+	}
+	s.id, _, _ = unstructured.NestedString(u.Object, "status", "devworkspaceId")
+	s.phase, _, _ = unstructured.NestedString(u.Object, "status", "phase")
+	s.message, _, _ = unstructured.NestedString(u.Object, "status", "message")
+	s.url, _, _ = unstructured.NestedString(u.Object, "status", "mainUrl")
+	s.started, _, _ = unstructured.NestedBool(u.Object, "spec", "started")
+	return s
+}
@@ -0,0 +1,516 @@
+package pkg
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	gitignore "github.com/monochromegane/go-gitignore"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+const (
+	// projectsRoot is where the DevWorkspace Operator mounts the projects volume
+	projectsRoot = "/projects"
+	// syncInterval is the time between two --watch synchronizations
+	syncInterval   = 2 * time.Second
+	gitIgnoreFile  = ".gitignore"
+	gitDir         = ".git"
+	remoteScanCmd  = `mkdir -p "$1" && cd "$1" && find . -type f -exec stat -c '%Y %s %n' {} + && echo ` + remoteScanEnd
+	remoteUntarCmd = `mkdir -p "$1" && tar xf - -C "$1"`
+	remoteTarCmd   = `tar cf - -C "$1" -T -`
+	remoteRmCmd    = `cd "$1" && xargs -0 rm -f --`
+	// remoteScanEnd ends the output of remoteScanCmd, that is incomplete without it
+	remoteScanEnd = "debug-ide-scan-end"
+)
+
+// execFunc runs a command in a container, streaming stdin and stdout if not nil
+type execFunc func(ctx context.Context, command []string, stdin io.Reader, stdout io.Writer) error
+
+// podExec returns an execFunc that runs commands in a container of the Pod podName
+func podExec(clients *kubeClients, podName, container string) execFunc {
+	return func(ctx context.Context, command []string, stdin io.Reader, stdout io.Writer) error {
+		req := clients.clientset.CoreV1().RESTClient().Post().
+			Resource("pods").
+			Namespace(clients.namespace).
+			Name(podName).
+			SubResource("exec").
+			VersionedParams(&corev1.PodExecOptions{
+				Container: container,
+				Command:   command,
+				Stdin:     stdin != nil,
+				Stdout:    stdout != nil,
+				Stderr:    true,
+			}, scheme.ParameterCodec)
+		executor, err := remotecommand.NewSPDYExecutor(clients.config, http.MethodPost, req.URL())
+		if err != nil {
+			return fmt.Errorf("error creating the exec transport: %v", err)
+		}
+		var stderr bytes.Buffer
+		err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{Stdin: stdin, Stdout: stdout, Stderr: &stderr})
+		if err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return fmt.Errorf("%v: %s", err, msg)
+			}
+			return err
+		}
+		return nil
+	}
+}
+
+// sourceProject returns the absolute path of the --source-dir and the name
+// of the project folder where it's uploaded
+func sourceProject(dir string) (string, string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid source directory %s: %v", dir, err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid source directory %s: %v", dir, err)
+	}
+	if !info.IsDir() {
+		return "", "", fmt.Errorf("invalid source directory %s: not a directory", dir)
+	}
+	return abs, filepath.Base(abs), nil
+}
+
+// ignoreMatcher matches the paths, relative to root and slash separated,
+// ignored by the .gitignore files of the tree. The .git folders are
+// always ignored.
+type ignoreMatcher struct {
+	root     string
+	patterns map[string]gitignore.IgnoreMatcher
+}
+
+func newIgnoreMatcher(root string) *ignoreMatcher {
+	return &ignoreMatcher{root: root, patterns: map[string]gitignore.IgnoreMatcher{}}
+}
+
+// load reads the .gitignore of the directory dir, if any
+func (m *ignoreMatcher) load(dir string) error {
+	abs := filepath.Join(m.root, filepath.FromSlash(dir))
+	g, err := gitignore.NewGitIgnore(filepath.Join(abs, gitIgnoreFile), abs)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", filepath.Join(abs, gitIgnoreFile), err)
+	}
+	m.patterns[dir] = g
+	return nil
+}
+
+// ignored returns true if rel, or one of its parent directories, is ignored
+func (m *ignoreMatcher) ignored(rel string, isDir bool) bool {
+	parts := strings.Split(rel, "/")
+	for i := range parts {
+		dir := isDir || i < len(parts)-1
+		if dir && parts[i] == gitDir {
+			return true
+		}
+		if m.match(strings.Join(parts[:i+1], "/"), dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// match checks rel against the .gitignore files of the directories that contain it
+func (m *ignoreMatcher) match(rel string, isDir bool) bool {
+	abs := filepath.Join(m.root, filepath.FromSlash(rel))
+	for dir := path.Dir(rel); ; dir = path.Dir(dir) {
+		if g, ok := m.patterns[dir]; ok && g.Match(abs, isDir) {
+			return true
+		}
+		if dir == "." {
+			return false
+		}
+	}
+}
+
+// fileState is what tells if a file has changed. The modification
+// time is in seconds, the precision that tar preserves.
+type fileState struct {
+	size    int64
+	modTime int64
+}
+
+// snapshot is the state of the files of a tree by relative, slash separated, path
+type snapshot map[string]fileState
+
+// scanLocal returns the regular files of root that aren't ignored
+func scanLocal(root string) (snapshot, *ignoreMatcher, error) {
+	files := snapshot{}
+	ignore := newIgnoreMatcher(root)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel != "." && ignore.ignored(rel, true) {
+				return filepath.SkipDir
+			}
+			return ignore.load(rel)
+		}
+		if !d.Type().IsRegular() || ignore.ignored(rel, false) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files[rel] = fileState{size: info.Size(), modTime: info.ModTime().Unix()}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading source directory %s: %v", root, err)
+	}
+	return files, ignore, nil
+}
+
+// parseRemoteSnapshot parses the output of the remote scan, lines formatted
+// as "<mtime> <size> ./<path>" followed by remoteScanEnd, skipping the ignored files
+func parseRemoteSnapshot(out string, ignore *ignoreMatcher) (snapshot, error) {
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if lines[len(lines)-1] != remoteScanEnd {
+		return nil, fmt.Errorf("incomplete listing")
+	}
+	files := snapshot{}
+	for _, line := range lines[:len(lines)-1] {
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected line %q", line)
+		}
+		seconds, _, _ := strings.Cut(fields[0], ".")
+		modTime, err := strconv.ParseInt(seconds, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected modification time in %q", line)
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected size in %q", line)
+		}
+		name, found := strings.CutPrefix(fields[2], "./")
+		if !found {
+			return nil, fmt.Errorf("unexpected path in %q", line)
+		}
+		if ignore.ignored(name, false) {
+			continue
+		}
+		files[name] = fileState{size: size, modTime: modTime}
+	}
+	return files, nil
+}
+
+// changes returns the files of cur that are new or modified since prev
+// and the files of prev that have been removed
+func changes(prev, cur snapshot) ([]string, []string) {
+	var changed, removed []string
+	for p, s := range cur {
+		if old, ok := prev[p]; !ok || old != s {
+			changed = append(changed, p)
+		}
+	}
+	for p := range prev {
+		if _, ok := cur[p]; !ok {
+			removed = append(removed, p)
+		}
+	}
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return changed, removed
+}
+
+// writeTar writes the files of root to w as a tar archive
+func writeTar(w io.Writer, root string, files []string) error {
+	tw := tar.NewWriter(w)
+	for _, f := range files {
+		if err := addTarFile(tw, root, f); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func addTarFile(tw *tar.Writer, root, name string) error {
+	file, err := os.Open(filepath.Join(root, filepath.FromSlash(name)))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	hdr.ModTime = info.ModTime().Truncate(time.Second)
+	hdr.AccessTime, hdr.ChangeTime = time.Time{}, time.Time{}
+	hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, file)
+	return err
+}
+
+// extractTar extracts the regular files of a tar archive in root,
+// preserving their modification time
+func extractTar(r io.Reader, root string) ([]string, error) {
+	var files []string
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return files, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return files, fmt.Errorf("invalid file name in archive: %s", hdr.Name)
+		}
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			return files, err
+		}
+		f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, hdr.FileInfo().Mode().Perm())
+		if err != nil {
+			return files, err
+		}
+		_, err = io.Copy(f, tr)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return files, err
+		}
+		if err := os.Chtimes(p, hdr.ModTime, hdr.ModTime); err != nil {
+			return files, err
+		}
+		files = append(files, name)
+	}
+}
+
+// sourceSync uploads a local directory to a container and, in watch
+// mode, keeps them in sync
+type sourceSync struct {
+	localDir  string
+	remoteDir string
+	exec      execFunc
+	out       io.Writer
+	errOut    io.Writer
+
+	ignore *ignoreMatcher
+	local  snapshot
+	remote snapshot
+}
+
+func newSourceSync(localDir, remoteDir string, exec execFunc, out, errOut io.Writer) *sourceSync {
+	return &sourceSync{
+		localDir:  localDir,
+		remoteDir: remoteDir,
+		exec:      exec,
+		out:       out,
+		errOut:    errOut,
+	}
+}
+
+// upload copies the files of the local directory that aren't
+// ignored and returns how many they are
+func (s *sourceSync) upload(ctx context.Context) (int, error) {
+	local, ignore, err := scanLocal(s.localDir)
+	if err != nil {
+		return 0, err
+	}
+	files := make([]string, 0, len(local))
+	for f := range local {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	if err := s.put(ctx, files); err != nil {
+		return 0, err
+	}
+	s.local, s.ignore = local, ignore
+	s.remote, err = s.scanRemote(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return len(files), nil
+}
+
+// watch synchronizes the changes of both sides every syncInterval until
+// ctx is done. When a file changes on both sides the local one wins.
+func (s *sourceSync) watch(ctx context.Context) {
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.sync(ctx); err != nil && ctx.Err() == nil {
+				fmt.Fprintf(s.errOut, "warning: sync failed: %v\n", err)
+			}
+		}
+	}
+}
+
+func (s *sourceSync) sync(ctx context.Context) error {
+	local, ignore, err := scanLocal(s.localDir)
+	if err != nil {
+		return err
+	}
+	s.ignore = ignore
+	remote, err := s.scanRemote(ctx)
+	if err != nil {
+		return err
+	}
+	localChanged, localRemoved := changes(s.local, local)
+	remoteChanged, remoteRemoved := changes(s.remote, remote)
+
+	uploaded := map[string]bool{}
+	for _, f := range localChanged {
+		uploaded[f] = true
+	}
+	var download, removeLocal []string
+	for _, f := range remoteChanged {
+		if uploaded[f] {
+			fmt.Fprintf(s.errOut, "warning: %s changed locally and in the container, keeping the local one\n", f)
+			continue
+		}
+		download = append(download, f)
+	}
+	if len(remote) == 0 && len(remoteRemoved) > 0 {
+		// An emptied directory is more likely a restarted container than
+		// deletions: the local files are kept, and uploaded again
+		fmt.Fprintf(s.errOut, "warning: %s is empty in the container, keeping the local files\n", s.remoteDir)
+		localChanged, _ = changes(nil, local)
+		remoteRemoved = nil
+		for _, f := range localChanged {
+			uploaded[f] = true
+		}
+	}
+	for _, f := range remoteRemoved {
+		if !uploaded[f] {
+			removeLocal = append(removeLocal, f)
+		}
+	}
+
+	if err := s.put(ctx, localChanged); err != nil {
+		return err
+	}
+	for _, f := range localChanged {
+		remote[f] = local[f]
+	}
+	if err := s.removeRemote(ctx, localRemoved); err != nil {
+		return err
+	}
+	for _, f := range localRemoved {
+		delete(remote, f)
+	}
+	downloaded, err := s.get(ctx, download)
+	for _, f := range downloaded {
+		local[f] = remote[f]
+	}
+	s.local, s.remote = local, remote
+	if err != nil {
+		return err
+	}
+	for _, f := range removeLocal {
+		if err := os.Remove(filepath.Join(s.localDir, filepath.FromSlash(f))); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		delete(s.local, f)
+	}
+
+	if n := len(localChanged) + len(localRemoved) + len(downloaded) + len(removeLocal); n > 0 {
+		fmt.Fprintf(s.out, "🔄 %s synced: %d uploaded, %d downloaded, %d deleted\n",
+			time.Now().Format(time.TimeOnly), len(localChanged), len(downloaded), len(localRemoved)+len(removeLocal))
+	}
+	return nil
+}
+
+func (s *sourceSync) scanRemote(ctx context.Context) (snapshot, error) {
+	var out bytes.Buffer
+	if err := s.exec(ctx, []string{"sh", "-c", remoteScanCmd, "sh", s.remoteDir}, nil, &out); err != nil {
+		return nil, fmt.Errorf("error listing %s: %v", s.remoteDir, err)
+	}
+	files, err := parseRemoteSnapshot(out.String(), s.ignore)
+	if err != nil {
+		return nil, fmt.Errorf("error listing %s: %v", s.remoteDir, err)
+	}
+	return files, nil
+}
+
+// put uploads files to the container
+func (s *sourceSync) put(ctx context.Context, files []string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(writeTar(w, s.localDir, files))
+	}()
+	err := s.exec(ctx, []string{"sh", "-c", remoteUntarCmd, "sh", s.remoteDir}, r, io.Discard)
+	r.Close()
+	if err != nil {
+		return fmt.Errorf("error uploading to %s: %v", s.remoteDir, err)
+	}
+	return nil
+}
+
+// get downloads files from the container and returns the ones extracted
+func (s *sourceSync) get(ctx context.Context, files []string) ([]string, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	var archive bytes.Buffer
+	stdin := strings.NewReader(strings.Join(files, "\n") + "\n")
+	if err := s.exec(ctx, []string{"sh", "-c", remoteTarCmd, "sh", s.remoteDir}, stdin, &archive); err != nil {
+		return nil, fmt.Errorf("error downloading from %s: %v", s.remoteDir, err)
+	}
+	extracted, err := extractTar(&archive, s.localDir)
+	if err != nil {
+		return extracted, fmt.Errorf("error extracting the files downloaded from %s: %v", s.remoteDir, err)
+	}
+	return extracted, nil
+}
+
+func (s *sourceSync) removeRemote(ctx context.Context, files []string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	stdin := strings.NewReader(strings.Join(files, "\x00"))
+	if err := s.exec(ctx, []string{"sh", "-c", remoteRmCmd, "sh", s.remoteDir}, stdin, nil); err != nil {
+		return fmt.Errorf("error deleting files in %s: %v", s.remoteDir, err)
+	}
+	return nil
+}
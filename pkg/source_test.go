@@ -0,0 +1,343 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func snapshotFiles(s snapshot) []string {
+	files := make([]string, 0, len(s))
+	for f := range s {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func Test_scanLocal(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".gitignore":           "*.log\nbin/\n",
+		".git/HEAD":            "ref: refs/heads/main\n",
+		"main.go":              "package main\n",
+		"debug.log":            "",
+		"bin/outyet":           "",
+		"pkg/.gitignore":       "generated.go\n!keep.log\n",
+		"pkg/outyet.go":        "package pkg\n",
+		"pkg/generated.go":     "package pkg\n",
+		"pkg/trace.log":        "",
+		"docs/generated.go":    "",
+		"docs/bin/install.md":  "",
+		"docs/index.md":        "",
+		"docs/sub/.gitignore":  "*.md\n",
+		"docs/sub/readme.md":   "",
+		"docs/sub/readme.html": "",
+	})
+
+	files, ignore, err := scanLocal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		".gitignore",
+		"docs/generated.go",
+		"docs/index.md",
+		"docs/sub/.gitignore",
+		"docs/sub/readme.html",
+		"main.go",
+		"pkg/.gitignore",
+		"pkg/outyet.go",
+	}
+	if got := snapshotFiles(files); !reflect.DeepEqual(got, want) {
+		t.Errorf("scanLocal() = %v, want %v", got, want)
+	}
+	if !ignore.ignored("bin/outyet/new", false) || ignore.ignored("cmd/main.go", false) {
+		t.Errorf("ignored() doesn't match the paths that aren't in the local directory")
+	}
+}
+
+func Test_parseRemoteSnapshot(t *testing.T) {
+	out := "1712345678 14 ./main.go\n1712345679 0 ./with space.txt\n1712345680 3 ./debug.log\n" + remoteScanEnd + "\n"
+	ignore := newIgnoreMatcher(t.TempDir())
+	writeFiles(t, ignore.root, map[string]string{".gitignore": "*.log\n"})
+	if err := ignore.load("."); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := parseRemoteSnapshot(out, ignore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := snapshot{
+		"main.go":        {size: 14, modTime: 1712345678},
+		"with space.txt": {size: 0, modTime: 1712345679},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseRemoteSnapshot() = %v, want %v", got, want)
+	}
+
+	for _, out := range []string{
+		"main.go\n" + remoteScanEnd + "\n",
+		"1712345678 14 main.go\n" + remoteScanEnd + "\n",
+		"1712345678 14 ./main.go\n",
+		"",
+	} {
+		if _, err := parseRemoteSnapshot(out, ignore); err == nil {
+			t.Errorf("parseRemoteSnapshot(%q) expected an error", out)
+		}
+	}
+}
+
+func Test_changes(t *testing.T) {
+	prev := snapshot{
+		"main.go":   {size: 14, modTime: 1},
+		"go.mod":    {size: 20, modTime: 1},
+		"README.md": {size: 5, modTime: 1},
+	}
+	cur := snapshot{
+		"main.go":   {size: 14, modTime: 2},
+		"go.mod":    {size: 20, modTime: 1},
+		"outyet.go": {size: 3, modTime: 2},
+	}
+	changed, removed := changes(prev, cur)
+	if want := []string{"main.go", "outyet.go"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("changes() changed = %v, want %v", changed, want)
+	}
+	if want := []string{"README.md"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("changes() removed = %v, want %v", removed, want)
+	}
+}
+
+func Test_extractTar(t *testing.T) {
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{"main.go": "package main\n", "pkg/outyet.go": "package pkg\n"})
+	modTime := time.Date(2024, 4, 5, 10, 20, 30, 500, time.UTC)
+	if err := os.Chtimes(filepath.Join(src, "main.go"), modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	if err := writeTar(&archive, src, []string{"main.go", "pkg/outyet.go"}); err != nil {
+		t.Fatal(err)
+	}
+	dst := t.TempDir()
+	files, err := extractTar(&archive, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"main.go", "pkg/outyet.go"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("extractTar() = %v, want %v", files, want)
+	}
+	info, err := os.Stat(filepath.Join(dst, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(modTime.Truncate(time.Second)) {
+		t.Errorf("extractTar() modification time = %v, want %v", info.ModTime(), modTime.Truncate(time.Second))
+	}
+}
+
+// fakeContainerExec emulates the sync commands on a local directory
+func fakeContainerExec(root string) execFunc {
+	return func(_ context.Context, command []string, stdin io.Reader, stdout io.Writer) error {
+		switch command[2] {
+		case remoteScanCmd:
+			err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return err
+				}
+				info, err := d.Info()
+				if err != nil {
+					return err
+				}
+				rel, _ := filepath.Rel(root, p)
+				_, err = fmt.Fprintf(stdout, "%d %d ./%s\n", info.ModTime().Unix(), info.Size(), filepath.ToSlash(rel))
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(stdout, remoteScanEnd)
+			return err
+		case remoteUntarCmd:
+			_, err := extractTar(stdin, root)
+			return err
+		case remoteTarCmd:
+			var files []string
+			scanner := bufio.NewScanner(stdin)
+			for scanner.Scan() {
+				files = append(files, scanner.Text())
+			}
+			return writeTar(stdout, root, files)
+		case remoteRmCmd:
+			b, err := io.ReadAll(stdin)
+			if err != nil {
+				return err
+			}
+			for _, f := range strings.Split(string(b), "\x00") {
+				if err := os.Remove(filepath.Join(root, filepath.FromSlash(f))); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return fmt.Errorf("unexpected command %v", command)
+	}
+}
+
+func Test_sourceSync(t *testing.T) {
+	local, remote := t.TempDir(), t.TempDir()
+	writeFiles(t, local, map[string]string{
+		".gitignore": "*.log\n",
+		"main.go":    "package main\n",
+		"outyet.go":  "package main\n",
+		"debug.log":  "",
+	})
+	var out, errOut bytes.Buffer
+	s := newSourceSync(local, remote, fakeContainerExec(remote), &out, &errOut)
+	ctx := context.Background()
+
+	n, err := s.upload(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("upload() = %d files, want 3", n)
+	}
+	if _, err := os.Stat(filepath.Join(remote, "debug.log")); !os.IsNotExist(err) {
+		t.Errorf("upload() copied an ignored file")
+	}
+
+	// Changes on both sides, older than a second so that the modification times differ
+	past := time.Now().Add(-time.Hour)
+	writeFiles(t, local, map[string]string{"main.go": "package main\n\nfunc main() {}\n", "both.go": "local"})
+	writeFiles(t, remote, map[string]string{"build.go": "package main\n", "both.go": "remote", "trace.log": ""})
+	for _, p := range []string{filepath.Join(remote, "build.go"), filepath.Join(remote, "both.go")} {
+		if err := os.Chtimes(p, past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Remove(filepath.Join(local, "outyet.go")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		".gitignore": "*.log\n",
+		"main.go":    "package main\n\nfunc main() {}\n",
+		"build.go":   "package main\n",
+		"both.go":    "local",
+	}
+	for _, root := range []string{local, remote} {
+		for f, content := range want {
+			b, err := os.ReadFile(filepath.Join(root, f))
+			if err != nil || string(b) != content {
+				t.Errorf("sync() %s = %q, %v, want %q", filepath.Join(root, f), b, err, content)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(root, "outyet.go")); !os.IsNotExist(err) {
+			t.Errorf("sync() didn't delete %s", filepath.Join(root, "outyet.go"))
+		}
+	}
+	if _, err := os.Stat(filepath.Join(local, "trace.log")); !os.IsNotExist(err) {
+		t.Errorf("sync() downloaded an ignored file")
+	}
+	if !strings.Contains(errOut.String(), "both.go changed locally and in the container") {
+		t.Errorf("sync() didn't report the conflict: %q", errOut.String())
+	}
+
+	// Nothing changed: no echo of the previous sync
+	out.Reset()
+	if err := s.sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() > 0 {
+		t.Errorf("sync() without changes reported %q", out.String())
+	}
+}
+
+func Test_sourceSync_remoteScan(t *testing.T) {
+	local, remote := t.TempDir(), t.TempDir()
+	writeFiles(t, local, map[string]string{"main.go": "package main\n", "outyet.go": "package main\n"})
+	var out, errOut bytes.Buffer
+	exec := fakeContainerExec(remote)
+	s := newSourceSync(local, remote, exec, &out, &errOut)
+	ctx := context.Background()
+	if _, err := s.upload(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A scan interrupted after a partial listing
+	s.exec = func(ctx context.Context, command []string, stdin io.Reader, stdout io.Writer) error {
+		if command[2] == remoteScanCmd {
+			fmt.Fprintf(stdout, "1712345678 14 ./main.go\n")
+			return fmt.Errorf("connection reset")
+		}
+		return exec(ctx, command, stdin, stdout)
+	}
+	if err := s.sync(ctx); err == nil {
+		t.Errorf("sync() with a failed remote scan, want an error")
+	}
+	s.exec = func(ctx context.Context, command []string, stdin io.Reader, stdout io.Writer) error {
+		if command[2] == remoteScanCmd {
+			fmt.Fprintf(stdout, "1712345678 14 ./main.go\n")
+			return nil
+		}
+		return exec(ctx, command, stdin, stdout)
+	}
+	if err := s.sync(ctx); err == nil {
+		t.Errorf("sync() with a partial remote scan, want an error")
+	}
+	for _, f := range []string{"main.go", "outyet.go"} {
+		if _, err := os.Stat(filepath.Join(local, f)); err != nil {
+			t.Errorf("sync() deleted %s after a failed remote scan: %v", f, err)
+		}
+	}
+
+	// The container restarted with an empty directory
+	s.exec = exec
+	for _, f := range []string{"main.go", "outyet.go"} {
+		if err := os.Remove(filepath.Join(remote, f)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, root := range []string{local, remote} {
+		for _, f := range []string{"main.go", "outyet.go"} {
+			if _, err := os.Stat(filepath.Join(root, f)); err != nil {
+				t.Errorf("sync() with an emptied remote directory: %v", err)
+			}
+		}
+	}
+	if !strings.Contains(errOut.String(), "is empty in the container") {
+		t.Errorf("sync() didn't report the emptied remote directory: %q", errOut.String())
+	}
+}
@@ -0,0 +1,357 @@
+package pkg
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	dwv1alpha2 "github.com/devfile/api/v2/pkg/apis/workspaces/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	sshPort               = 2022
+	sshEndpointName       = "ssh"
+	sshSecretSuffix       = "-ssh"
+	sshKeysMountPath      = "/etc/ssh-keys"
+	sshKeysVolumeName     = "debug-ide-ssh-keys"
+	sshAuthorizedKeysFile = "authorized_keys"
+	sshdCommandID         = "start-sshd"
+	sshKeyType            = "ecdsa-sha2-nistp256"
+	sshKeyCurve           = "nistp256"
+	sshKeyFilePrefix      = "kubectl-debug-ide-"
+	sshProxyCommandName   = "ssh-proxy"
+	// defaultSSHUser is the user of the universal developer image
+	defaultSSHUser = "user"
+	// sshdScript starts sshd as the container user. It never fails
+	// as a failing postStart command would kill the container.
+	sshdScript = `SSHD_DIR=/tmp/sshd
+mkdir -p "$SSHD_DIR"
+SSHD=$(command -v sshd || echo /usr/sbin/sshd)
+if [ ! -x "$SSHD" ]; then
+  echo "sshd not found in the image" > "$SSHD_DIR/sshd.log"
+  exit 0
+fi
+[ -f "$SSHD_DIR/host_key" ] || ssh-keygen -q -t ecdsa -N "" -f "$SSHD_DIR/host_key"
+"$SSHD" -f /dev/null -p %d -h "$SSHD_DIR/host_key" \
+  -o AuthorizedKeysFile=%s/%s -o StrictModes=no -o UsePAM=no \
+  -o PasswordAuthentication=no -o PidFile="$SSHD_DIR/sshd.pid" -E "$SSHD_DIR/sshd.log" || true
+`
+)
+
+// sshKey is the key used to connect to the debugging container. The
+// private key is empty when the public key is provided by the user.
+type sshKey struct {
+	authorizedKey string
+	privateKey    []byte
+}
+
+// generateSSHKey generates an ECDSA P-256 key pair
+func generateSSHKey() (sshKey, error) {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return sshKey{}, fmt.Errorf("error generating the ssh key: %v", err)
+	}
+	der, err := x509.MarshalECPrivateKey(k)
+	if err != nil {
+		return sshKey{}, fmt.Errorf("error encoding the ssh key: %v", err)
+	}
+	pub, err := authorizedKey(&k.PublicKey)
+	if err != nil {
+		return sshKey{}, fmt.Errorf("error encoding the ssh public key: %v", err)
+	}
+	return sshKey{
+		authorizedKey: pub,
+		privateKey:    pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}),
+	}, nil
+}
+
+// authorizedKey returns a P-256 public key in the authorized_keys format (RFC 5656)
+func authorizedKey(k *ecdsa.PublicKey) (string, error) {
+	ecdhKey, err := k.ECDH()
+	if err != nil {
+		return "", err
+	}
+	// the uncompressed point encoding
+	point := ecdhKey.Bytes()
+	var b []byte
+	for _, field := range [][]byte{[]byte(sshKeyType), []byte(sshKeyCurve), point} {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(field)))
+		b = append(b, field...)
+	}
+	return sshKeyType + " " + base64.StdEncoding.EncodeToString(b), nil
+}
+
+func readSSHPublicKey(p string) (sshKey, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return sshKey{}, fmt.Errorf("error reading ssh public key %s: %v", p, err)
+	}
+	key := strings.TrimSpace(string(b))
+	if len(strings.Fields(key)) < 2 {
+		return sshKey{}, fmt.Errorf("invalid ssh public key %s: expected the authorized_keys format", p)
+	}
+	return sshKey{authorizedKey: key}, nil
+}
+
+func sshSecretName(dwName string) string {
+	return dwName + sshSecretSuffix
+}
+
+// sshKeysVolume is the volume of the Secret with the authorized key,
+// mounted in the CDE container of the session only
+func sshKeysVolume(dwName string) sessionVolume {
+	return sessionVolume{
+		volume: corev1.Volume{
+			Name: sshKeysVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: sshSecretName(dwName)},
+			},
+		},
+		mount: corev1.VolumeMount{Name: sshKeysVolumeName, MountPath: sshKeysMountPath, ReadOnly: true},
+	}
+}
+
+// sshSecret returns the Secret with the authorized key
+func sshSecret(dwName string, key sshKey) *corev1.Secret {
+	return &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Secret",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   sshSecretName(dwName),
+			Labels: sessionLabels(),
+		},
+		StringData: map[string]string{
+			sshAuthorizedKeysFile: key.authorizedKey + "\n",
+		},
+	}
+}
+
+// applySecret creates, or updates if it already exists, a Secret. The
+// Secrets that weren't created by kubectl debug-ide are never replaced.
+func applySecret(ctx context.Context, clients *kubeClients, s *corev1.Secret) error {
+	secrets := clients.clientset.CoreV1().Secrets(clients.namespace)
+	existing, err := secrets.Get(ctx, s.Name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		if _, err := secrets.Create(ctx, s, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("error creating Secret %s: %v", s.Name, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting Secret %s: %v", s.Name, err)
+	}
+	if existing.Labels[managedByLabel] != managedByValue {
+		return fmt.Errorf("the Secret %s wasn't created by kubectl debug-ide and cannot be replaced", s.Name)
+	}
+	s.ResourceVersion = existing.ResourceVersion
+	if _, err := secrets.Update(ctx, s, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("error updating Secret %s: %v", s.Name, err)
+	}
+	return nil
+}
+
+// addSSHEndpoint adds the sshd endpoint to the CDE component
+func addSSHEndpoint(c *dwv1alpha2.Component) {
+	c.Container.Endpoints = append(c.Container.Endpoints, dwv1alpha2.Endpoint{
+		Name:       sshEndpointName,
+		TargetPort: sshPort,
+		Exposure:   dwv1alpha2.InternalEndpointExposure,
+		Protocol:   dwv1alpha2.TCPEndpointProtocol,
+	})
+}
+
+// sshdCommand is the postStart command that starts sshd in the CDE container
+func sshdCommand() dwv1alpha2.Command {
+	return dwv1alpha2.Command{
+		Id: sshdCommandID,
+		CommandUnion: dwv1alpha2.CommandUnion{
+			Exec: &dwv1alpha2.ExecCommand{
+				Component:   defaultDevContainerName,
+				CommandLine: fmt.Sprintf(sshdScript, sshPort, sshKeysMountPath, sshAuthorizedKeysFile),
+			},
+		},
+	}
+}
+
+// sshHost is the ssh config Host alias of a debugging session, that
+// includes the namespace as sessions of different namespaces can have
+// the same name
+func sshHost(namespace, dwName string) string {
+	return dwName + "." + namespace
+}
+
+func sshConfigMarkers(namespace, dwName string) (string, string) {
+	id := namespace + "/" + dwName
+	return "# BEGIN kubectl debug-ide " + id, "# END kubectl debug-ide " + id
+}
+
+// sshConfigEntry returns the ssh config Host entry of a debugging session
+func sshConfigEntry(namespace, dwName, identityFile, proxyCommand string) string {
+	begin, end := sshConfigMarkers(namespace, dwName)
+	lines := []string{
+		begin,
+		"Host " + sshHost(namespace, dwName),
+		"  HostName " + sshHost(namespace, dwName),
+		"  User " + defaultSSHUser,
+	}
+	if identityFile != "" {
+		lines = append(lines, "  IdentityFile "+identityFile)
+	}
+	lines = append(lines,
+		"  ProxyCommand "+proxyCommand,
+		"  StrictHostKeyChecking no",
+		"  UserKnownHostsFile /dev/null",
+		end,
+	)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// setSSHConfigEntry replaces the entry of a debugging session in the ssh
+// config, or adds it at the beginning as the first obtained value of a
+// parameter is the one used by ssh. An empty entry removes it.
+func setSSHConfigEntry(config, namespace, dwName, entry string) string {
+	begin, end := sshConfigMarkers(namespace, dwName)
+	if i := strings.Index(config, begin+"\n"); i >= 0 {
+		if j := strings.Index(config[i:], end+"\n"); j >= 0 {
+			rest := config[i+j+len(end)+1:]
+			if entry == "" {
+				rest = strings.TrimPrefix(rest, "\n")
+			}
+			return config[:i] + entry + rest
+		}
+	}
+	if entry == "" {
+		return config
+	}
+	if config == "" {
+		return entry
+	}
+	return entry + "\n" + config
+}
+
+func sshDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error getting the home directory: %v", err)
+	}
+	return filepath.Join(home, ".ssh"), nil
+}
+
+func sshKeyFile(dir, namespace, dwName string) string {
+	return filepath.Join(dir, sshKeyFilePrefix+namespace+"-"+dwName)
+}
+
+func updateSSHConfig(dir, namespace, dwName, entry string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("error creating %s: %v", dir, err)
+	}
+	p := filepath.Join(dir, "config")
+	b, err := os.ReadFile(p)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading %s: %v", p, err)
+	}
+	config := setSSHConfigEntry(string(b), namespace, dwName, entry)
+	if err := os.WriteFile(p, []byte(config), 0o600); err != nil {
+		return fmt.Errorf("error writing %s: %v", p, err)
+	}
+	return nil
+}
+
+// configureSSH writes the private key, if it has been generated, and the
+// ssh config entry that connects to the debugging session
+func (o *DebugIDEOptions) configureSSH(dwName string) error {
+	dir, err := sshDir()
+	if err != nil {
+		return err
+	}
+	namespace := o.clients.namespace
+
+	identityFile := ""
+	if len(o.sshKey.privateKey) > 0 {
+		identityFile = sshKeyFile(dir, namespace, dwName)
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("error creating %s: %v", dir, err)
+		}
+		if err := os.WriteFile(identityFile, o.sshKey.privateKey, 0o600); err != nil {
+			return fmt.Errorf("error writing ssh key %s: %v", identityFile, err)
+		}
+	}
+
+	proxyCommand, err := o.sshProxyCommand(dwName)
+	if err != nil {
+		return err
+	}
+	return updateSSHConfig(dir, namespace, dwName, sshConfigEntry(namespace, dwName, identityFile, proxyCommand))
+}
+
+// sshProxyCommand returns the command line of the ssh-proxy subcommand
+// of this executable, using the current kubeconfig, context and namespace
+func (o *DebugIDEOptions) sshProxyCommand(dwName string) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("error getting the executable path: %v", err)
+	}
+	args := []string{fmt.Sprintf("%q", exe), sshProxyCommandName, "--namespace", o.clients.namespace}
+	if o.configFlags.KubeConfig != nil && *o.configFlags.KubeConfig != "" {
+		kubeconfig, err := filepath.Abs(*o.configFlags.KubeConfig)
+		if err != nil {
+			return "", fmt.Errorf("error getting the kubeconfig path: %v", err)
+		}
+		args = append(args, "--kubeconfig", fmt.Sprintf("%q", kubeconfig))
+	}
+	kubeContext := ""
+	if o.configFlags.Context != nil {
+		kubeContext = *o.configFlags.Context
+	}
+	if kubeContext == "" {
+		rawConfig, err := o.configFlags.ToRawKubeConfigLoader().RawConfig()
+		if err != nil {
+			return "", fmt.Errorf("error reading the kubeconfig: %v", err)
+		}
+		kubeContext = rawConfig.CurrentContext
+	}
+	if kubeContext != "" {
+		args = append(args, "--context", fmt.Sprintf("%q", kubeContext))
+	}
+	return strings.Join(append(args, dwName), " "), nil
+}
+
+// removeSSHConfig removes the ssh config entry and the private key of a
+// debugging session, if any
+func removeSSHConfig(namespace, dwName string) error {
+	dir, err := sshDir()
+	if err != nil {
+		return err
+	}
+	p := filepath.Join(dir, "config")
+	b, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", p, err)
+	}
+	if config := setSSHConfigEntry(string(b), namespace, dwName, ""); config != string(b) {
+		if err := os.WriteFile(p, []byte(config), 0o600); err != nil {
+			return fmt.Errorf("error writing %s: %v", p, err)
+		}
+	}
+	if err := os.Remove(sshKeyFile(dir, namespace, dwName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing ssh key: %v", err)
+	}
+	return nil
+}
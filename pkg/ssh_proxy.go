@@ -0,0 +1,156 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/client-go/tools/portforward"
+)
+
+// SSHProxyOptions provides information required to connect the standard
+// input and output to the sshd of a debugging session. It's used as the
+// ssh ProxyCommand of the sessions created with --ssh.
+type SSHProxyOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+
+	name    string
+	clients *kubeClients
+
+	genericiooptions.IOStreams
+}
+
+// NewSSHProxyOptions provides an instance of SSHProxyOptions with default values
+func NewSSHProxyOptions(configFlags *genericclioptions.ConfigFlags, streams genericiooptions.IOStreams) *SSHProxyOptions {
+	return &SSHProxyOptions{
+		configFlags: configFlags,
+
+		IOStreams: streams,
+	}
+}
+
+// NewCmdSSHProxy provides a hidden cobra command wrapping SSHProxyOptions
+func NewCmdSSHProxy(configFlags *genericclioptions.ConfigFlags, streams genericiooptions.IOStreams) *cobra.Command {
+	o := NewSSHProxyOptions(configFlags, streams)
+
+	cmd := &cobra.Command{
+		Use:          sshProxyCommandName + " SESSION",
+		Short:        "Connect the standard input and output to the sshd of a debugging session.",
+		Args:         cobra.ExactArgs(1),
+		Hidden:       true,
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(args); err != nil {
+				return err
+			}
+			if err := o.Run(c.Context()); err != nil {
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+// Complete sets the session name and the clients
+func (o *SSHProxyOptions) Complete(args []string) error {
+	o.name = args[0]
+
+	var err error
+	o.clients, err = newKubeClients(o.configFlags)
+	return err
+}
+
+// Run forwards the sshd port of the session Pod to the standard input and output
+func (o *SSHProxyOptions) Run(ctx context.Context) error {
+	pods, err := o.clients.clientset.CoreV1().Pods(o.clients.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "controller.devfile.io/devworkspace_name=" + o.name,
+	})
+	if err != nil {
+		return fmt.Errorf("error listing the pods of the debugging session %s: %v", o.name, err)
+	}
+	pod := runningPod(pods.Items)
+	if pod == nil {
+		return fmt.Errorf("no running pod found for the debugging session %s", o.name)
+	}
+
+	dialer, err := portForwardDialer(o.clients, pod.Name)
+	if err != nil {
+		return err
+	}
+	return proxyStdio(ctx, dialer, sshPort, o.In, o.Out)
+}
+
+// proxyStdio copies in to a port of a Pod and the data received from
+// that port to out, as kubectl port-forward does for a connection
+func proxyStdio(ctx context.Context, dialer httpstream.Dialer, port int, in io.Reader, out io.Writer) error {
+	conn, _, err := dialer.Dial(portforward.PortForwardProtocolV1Name)
+	if err != nil {
+		return fmt.Errorf("error upgrading connection: %v", err)
+	}
+	defer conn.Close()
+
+	headers := http.Header{}
+	headers.Set(corev1.StreamType, corev1.StreamTypeError)
+	headers.Set(corev1.PortHeader, strconv.Itoa(port))
+	headers.Set(corev1.PortForwardRequestIDHeader, "0")
+	errorStream, err := conn.CreateStream(headers)
+	if err != nil {
+		return fmt.Errorf("error creating error stream for port %d: %v", port, err)
+	}
+	// the error stream is only read
+	errorStream.Close()
+
+	errCh := make(chan error, 1)
+	go func() {
+		message, err := io.ReadAll(errorStream)
+		switch {
+		case err != nil:
+			errCh <- fmt.Errorf("error reading from error stream for port %d: %v", port, err)
+		case len(message) > 0:
+			errCh <- fmt.Errorf("an error occurred forwarding port %d: %s", port, string(message))
+		}
+		close(errCh)
+	}()
+
+	headers.Set(corev1.StreamType, corev1.StreamTypeData)
+	dataStream, err := conn.CreateStream(headers)
+	if err != nil {
+		return fmt.Errorf("error creating forwarding stream for port %d: %v", port, err)
+	}
+
+	go func() {
+		_, _ = io.Copy(dataStream, in)
+		// inform the server that no more data will be sent
+		dataStream.Close()
+	}()
+
+	copyCh := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(out, dataStream)
+		copyCh <- err
+	}()
+
+	select {
+	case err := <-copyCh:
+		if err != nil {
+			return fmt.Errorf("error copying from remote stream: %v", err)
+		}
+	case <-ctx.Done():
+		return nil
+	}
+	if err := <-errCh; err != nil {
+		return err
+	}
+	return nil
+}
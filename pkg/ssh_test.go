@@ -0,0 +1,164 @@
+package pkg
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_generateSSHKey(t *testing.T) {
+	key, err := generateSSHKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := strings.Fields(key.authorizedKey)
+	if len(fields) != 2 || fields[0] != sshKeyType {
+		t.Fatalf("generateSSHKey() authorized key = %q, want %s <base64>", key.authorizedKey, sshKeyType)
+	}
+	b, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		t.Fatalf("generateSSHKey() authorized key is not base64: %v", err)
+	}
+	// 3 length prefixed fields: the key type, the curve and the 65 bytes point
+	if want := 4 + len(sshKeyType) + 4 + len(sshKeyCurve) + 4 + 65; len(b) != want {
+		t.Errorf("generateSSHKey() authorized key is %d bytes long, want %d", len(b), want)
+	}
+
+	block, _ := pem.Decode(key.privateKey)
+	if block == nil || block.Type != "EC PRIVATE KEY" {
+		t.Fatalf("generateSSHKey() private key is not a PEM EC key")
+	}
+	if _, err := x509.ParseECPrivateKey(block.Bytes); err != nil {
+		t.Errorf("generateSSHKey() private key cannot be parsed: %v", err)
+	}
+}
+
+// The authorized key is mounted in the CDE container of the
+// session only, where sshd looks for it
+func Test_sshSecret(t *testing.T) {
+	s := sshSecret("outyet-dw", sshKey{authorizedKey: "ssh-ed25519 AAAA"})
+	if _, mounted := s.Labels[mountToDevWorkspaceLabel]; mounted || s.Labels[managedByLabel] != managedByValue {
+		t.Errorf("sshSecret() labels = %v", s.Labels)
+	}
+	if got := s.StringData[sshAuthorizedKeysFile]; got != "ssh-ed25519 AAAA\n" {
+		t.Errorf("sshSecret() authorized keys = %q", got)
+	}
+	v := sshKeysVolume("outyet-dw")
+	if v.volume.Secret == nil || v.volume.Secret.SecretName != s.Name || v.mount.MountPath != "/etc/ssh-keys" {
+		t.Errorf("sshKeysVolume() = %v", v)
+	}
+	if cmd := sshdCommand(); !strings.Contains(cmd.Exec.CommandLine, "AuthorizedKeysFile=/etc/ssh-keys/authorized_keys") {
+		t.Errorf("sshdCommand() doesn't read the mounted key:\n%s", cmd.Exec.CommandLine)
+	}
+}
+
+func Test_setSSHConfigEntry(t *testing.T) {
+	entry := sshConfigEntry("dev", "outyet-dw", "/home/me/.ssh/kubectl-debug-ide-dev-outyet-dw",
+		`"/usr/local/bin/kubectl-debug_ide" ssh-proxy --namespace dev outyet-dw`)
+	other := "Host github.com\n  User git\n"
+	prodEntry := sshConfigEntry("prod", "outyet-dw", "/home/me/.ssh/kubectl-debug-ide-prod-outyet-dw",
+		`"/usr/local/bin/kubectl-debug_ide" ssh-proxy --namespace prod outyet-dw`)
+
+	wantEntry := `# BEGIN kubectl debug-ide dev/outyet-dw
+Host outyet-dw.dev
+  HostName outyet-dw.dev
+  User user
+  IdentityFile /home/me/.ssh/kubectl-debug-ide-dev-outyet-dw
+  ProxyCommand "/usr/local/bin/kubectl-debug_ide" ssh-proxy --namespace dev outyet-dw
+  StrictHostKeyChecking no
+  UserKnownHostsFile /dev/null
+# END kubectl debug-ide dev/outyet-dw
+`
+	if entry != wantEntry {
+		t.Fatalf("sshConfigEntry() got:\n%s\nwant:\n%s", entry, wantEntry)
+	}
+
+	tests := []struct {
+		name   string
+		config string
+		entry  string
+		want   string
+	}{
+		{
+			name:  "empty config",
+			entry: entry,
+			want:  entry,
+		},
+		{
+			name:   "entry added first",
+			config: other,
+			entry:  entry,
+			want:   entry + "\n" + other,
+		},
+		{
+			name:   "entry replaced",
+			config: strings.Replace(entry, "User user", "User root", 1) + "\n" + other,
+			entry:  entry,
+			want:   entry + "\n" + other,
+		},
+		{
+			name:   "session of another namespace kept",
+			config: prodEntry,
+			entry:  entry,
+			want:   entry + "\n" + prodEntry,
+		},
+		{
+			name:   "entry removed",
+			config: entry + "\n" + other,
+			want:   other,
+		},
+		{
+			name:   "missing entry removed",
+			config: other,
+			want:   other,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := setSSHConfigEntry(tt.config, "dev", "outyet-dw", tt.entry); got != tt.want {
+				t.Errorf("setSSHConfigEntry() got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_applySecret(t *testing.T) {
+	managed := sshSecret("outyet-dw", sshKey{authorizedKey: "ssh-ed25519 OLD"})
+	managed.Namespace = "dev"
+	unmanaged := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "other-dw-ssh", Namespace: "dev"}}
+	clients := &kubeClients{namespace: "dev", clientset: fake.NewClientset(managed, unmanaged)}
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		secret  *corev1.Secret
+		wantErr bool
+	}{
+		{name: "create", secret: sshSecret("new-dw", sshKey{authorizedKey: "ssh-ed25519 NEW"})},
+		{name: "update a session secret", secret: sshSecret("outyet-dw", sshKey{authorizedKey: "ssh-ed25519 NEW"})},
+		{name: "secret not created by the plugin", secret: sshSecret("other-dw", sshKey{authorizedKey: "ssh-ed25519 NEW"}), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := applySecret(ctx, clients, tt.secret)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applySecret() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got, err := clients.clientset.CoreV1().Secrets("dev").Get(ctx, tt.secret.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if replaced := got.StringData[sshAuthorizedKeysFile] == "ssh-ed25519 NEW\n"; replaced == tt.wantErr {
+				t.Errorf("applySecret() replaced = %v, want %v", replaced, !tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// targetArgs converts the command arguments in resource.Builder
+// arguments. A name without a resource type refers to a Pod, as with
+// kubectl debug, and a label selector without a resource type selects Pods.
+func targetArgs(args []string, selector string) ([]string, error) {
+	if len(selector) > 0 {
+		switch len(args) {
+		case 0:
+			return []string{"pods"}, nil
+		case 1:
+			if strings.Contains(args[0], "/") {
+				return nil, fmt.Errorf("cannot specify both a resource name and a label selector")
+			}
+			return args, nil
+		default:
+			return nil, fmt.Errorf("cannot specify more than one resource type with a label selector (args number is %d)", len(args))
+		}
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("cannot omit the target pod to debug")
+	}
+
+	if len(args) > 1 {
+		return nil, fmt.Errorf("cannot specify more than one pod (args number is %d)", len(args))
+	}
+
+	if !strings.Contains(args[0], "/") {
+		return []string{"pod/" + args[0]}, nil
+	}
+	return args, nil
+}
+
+// resolveTargetPod looks up the object referenced by args and selector
+// and returns the Pod to debug. For workloads a running Pod is picked if
+// there is one, otherwise a Pod is built from the workload Pod template.
+// The second value returned is true if the Pod is running in the cluster.
+func resolveTargetPod(
+	ctx context.Context,
+	builder *resource.Builder,
+	clientset kubernetes.Interface,
+	namespace string,
+	args []string,
+	selector string,
+) (*corev1.Pod, bool, error) {
+	builderArgs, err := targetArgs(args, selector)
+	if err != nil {
+		return nil, false, err
+	}
+
+	infos, err := builder.
+		WithScheme(scheme.Scheme, scheme.Scheme.PrioritizedVersionsAllGroups()...).
+		NamespaceParam(namespace).DefaultNamespace().
+		ResourceTypeOrNameArgs(true, builderArgs...).
+		LabelSelectorParam(selector).
+		SingleResourceType().
+		Flatten().
+		Do().
+		Infos()
+	if err != nil {
+		return nil, false, err
+	}
+	if len(infos) == 0 {
+		return nil, false, fmt.Errorf("no resources found in namespace %s matching %s", namespace, strings.Join(builderArgs, " "))
+	}
+
+	// When a label selector matches more than one object, prefer
+	// a running Pod to make the choice stable and meaningful
+	sort.SliceStable(infos, func(i, j int) bool {
+		return podRank(infos[i].Object) < podRank(infos[j].Object)
+	})
+
+	return podForObject(ctx, clientset, infos[0].Object)
+}
+
+func podForObject(ctx context.Context, clientset kubernetes.Interface, obj runtime.Object) (*corev1.Pod, bool, error) {
+	switch t := obj.(type) {
+	case *corev1.Pod:
+		return t, t.Status.Phase == corev1.PodRunning && t.DeletionTimestamp == nil, nil
+	case *appsv1.Deployment:
+		return podForWorkload(ctx, clientset, t.ObjectMeta, t.Spec.Selector, t.Spec.Template)
+	case *appsv1.StatefulSet:
+		return podForWorkload(ctx, clientset, t.ObjectMeta, t.Spec.Selector, t.Spec.Template)
+	case *appsv1.ReplicaSet:
+		return podForWorkload(ctx, clientset, t.ObjectMeta, t.Spec.Selector, t.Spec.Template)
+	case *appsv1.DaemonSet:
+		return podForWorkload(ctx, clientset, t.ObjectMeta, t.Spec.Selector, t.Spec.Template)
+	case *batchv1.Job:
+		return podForWorkload(ctx, clientset, t.ObjectMeta, t.Spec.Selector, t.Spec.Template)
+	case *batchv1.CronJob:
+		// CronJob Pods are short-lived: always use the template
+		return podFromTemplate(t.ObjectMeta, t.Spec.JobTemplate.Spec.Template), false, nil
+	case *corev1.ReplicationController:
+		s := &metav1.LabelSelector{MatchLabels: t.Spec.Selector}
+		if t.Spec.Template == nil {
+			return nil, false, fmt.Errorf("replicationcontroller %s has no pod template", t.Name)
+		}
+		return podForWorkload(ctx, clientset, t.ObjectMeta, s, *t.Spec.Template)
+	default:
+		return nil, false, fmt.Errorf("cannot debug objects of type %T", obj)
+	}
+}
+
+func podForWorkload(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	meta metav1.ObjectMeta,
+	labelSelector *metav1.LabelSelector,
+	template corev1.PodTemplateSpec,
+) (*corev1.Pod, bool, error) {
+	if labelSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid selector for %s: %v", meta.Name, err)
+		}
+		if !selector.Empty() && selector.String() != labels.Nothing().String() {
+			pods, err := clientset.CoreV1().Pods(meta.Namespace).List(ctx, metav1.ListOptions{
+				LabelSelector: selector.String(),
+			})
+			if err != nil {
+				return nil, false, fmt.Errorf("error listing pods of %s: %v", meta.Name, err)
+			}
+			if pod := runningPod(pods.Items); pod != nil {
+				return pod, true, nil
+			}
+		}
+	}
+	return podFromTemplate(meta, template), false, nil
+}
+
+// runningPod returns the first running Pod, preferring the ready ones
+func runningPod(pods []corev1.Pod) *corev1.Pod {
+	candidates := make([]corev1.Pod, 0, len(pods))
+	for _, p := range pods {
+		if p.Status.Phase == corev1.PodRunning && p.DeletionTimestamp == nil {
+			candidates = append(candidates, p)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		ri, rj := isPodReady(&candidates[i]), isPodReady(&candidates[j])
+		if ri != rj {
+			return ri
+		}
+		return candidates[i].Name < candidates[j].Name
+	})
+	return &candidates[0]
+}
+
+func podFromTemplate(meta metav1.ObjectMeta, template corev1.PodTemplateSpec) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        meta.Name,
+			Namespace:   meta.Namespace,
+			Labels:      template.Labels,
+			Annotations: template.Annotations,
+		},
+		Spec: *template.Spec.DeepCopy(),
+	}
+}
+
+func podRank(obj runtime.Object) int {
+	pod, ok := obj.(*corev1.Pod)
+	switch {
+	case !ok:
+		return 2
+	case pod.Status.Phase == corev1.PodRunning && isPodReady(pod):
+		return 0
+	case pod.Status.Phase == corev1.PodRunning:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
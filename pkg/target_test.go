@@ -0,0 +1,163 @@
+package pkg
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_targetArgs(t *testing.T) {
+	type args struct {
+		args     []string
+		selector string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "pod name",
+			args: args{args: []string{"outyet"}},
+			want: []string{"pod/outyet"},
+		},
+		{
+			name: "deployment",
+			args: args{args: []string{"deploy/outyet"}},
+			want: []string{"deploy/outyet"},
+		},
+		{
+			name: "selector with no resource type",
+			args: args{selector: "app=outyet"},
+			want: []string{"pods"},
+		},
+		{
+			name: "selector with resource type",
+			args: args{args: []string{"statefulsets"}, selector: "app=outyet"},
+			want: []string{"statefulsets"},
+		},
+		{
+			name:    "selector with resource name",
+			args:    args{args: []string{"deploy/outyet"}, selector: "app=outyet"},
+			wantErr: true,
+		},
+		{
+			name:    "no args",
+			args:    args{},
+			wantErr: true,
+		},
+		{
+			name:    "too many args",
+			args:    args{args: []string{"outyet", "outyet2"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := targetArgs(tt.args.args, tt.args.selector)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("targetArgs() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("targetArgs() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_runningPod(t *testing.T) {
+	pod := func(name string, phase corev1.PodPhase, ready bool) corev1.Pod {
+		status := corev1.ConditionFalse
+		if ready {
+			status = corev1.ConditionTrue
+		}
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: corev1.PodStatus{
+				Phase:      phase,
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+			},
+		}
+	}
+	tests := []struct {
+		name string
+		pods []corev1.Pod
+		want string
+	}{
+		{
+			name: "no pods",
+			pods: nil,
+			want: "",
+		},
+		{
+			name: "only pending pods",
+			pods: []corev1.Pod{pod("a", corev1.PodPending, false)},
+			want: "",
+		},
+		{
+			name: "ready pod preferred",
+			pods: []corev1.Pod{pod("a", corev1.PodRunning, false), pod("b", corev1.PodRunning, true)},
+			want: "b",
+		},
+		{
+			name: "sorted by name",
+			pods: []corev1.Pod{pod("b", corev1.PodRunning, true), pod("a", corev1.PodRunning, true)},
+			want: "a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if p := runningPod(tt.pods); p != nil {
+				got = p.Name
+			}
+			if got != tt.want {
+				t.Errorf("runningPod() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_podForObject(t *testing.T) {
+	pod := func(phase corev1.PodPhase, deleted bool) *corev1.Pod {
+		p := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "outyet"},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+		if deleted {
+			now := metav1.Now()
+			p.DeletionTimestamp = &now
+		}
+		return p
+	}
+	tests := []struct {
+		name        string
+		pod         *corev1.Pod
+		wantRunning bool
+	}{
+		{name: "running pod", pod: pod(corev1.PodRunning, false), wantRunning: true},
+		{name: "pending pod", pod: pod(corev1.PodPending, false), wantRunning: false},
+		{name: "succeeded pod", pod: pod(corev1.PodSucceeded, false), wantRunning: false},
+		{name: "failed pod", pod: pod(corev1.PodFailed, false), wantRunning: false},
+		{name: "terminating pod", pod: pod(corev1.PodRunning, true), wantRunning: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, running, err := podForObject(context.Background(), nil, tt.pod)
+			if err != nil {
+				t.Fatalf("podForObject() error = %v", err)
+			}
+			if got != tt.pod {
+				t.Errorf("podForObject() got = %v, want the target pod", got.Name)
+			}
+			if running != tt.wantRunning {
+				t.Errorf("podForObject() running = %v, want %v", running, tt.wantRunning)
+			}
+		})
+	}
+}
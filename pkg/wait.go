@@ -0,0 +1,233 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+)
+
+const (
+	defaultWaitTimeout = 5 * time.Minute
+	devWorkspaceFailed = "Failed"
+	devWorkspaceReady  = "Running"
+)
+
+// errWaitTimeout is returned when the wait timeout expires
+var errWaitTimeout = errors.New("timed out")
+
+// waitFor watches the objects returned by lw until condition is true.
+// The objects already existing are processed first, so that
+// the condition is evaluated even if no change happens.
+func waitFor(
+	ctx context.Context,
+	lw cache.ListerWatcher,
+	objType runtime.Object,
+	precondition watchtools.PreconditionFunc,
+	condition watchtools.ConditionFunc,
+) (runtime.Object, error) {
+	ev, err := watchtools.UntilWithSync(ctx, lw, objType, precondition, condition)
+	if err != nil {
+		if wait.Interrupted(err) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return nil, fmt.Errorf("interrupted")
+			}
+			return nil, errWaitTimeout
+		}
+		return nil, err
+	}
+	// No event when the precondition is already satisfied
+	if ev == nil {
+		return nil, nil
+	}
+	return ev.Object, nil
+}
+
+func nameSelector(name string) string {
+	return fields.OneTermEqualSelector("metadata.name", name).String()
+}
+
+func dynamicListWatch(ctx context.Context, client dynamic.ResourceInterface, name string) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = nameSelector(name)
+			return client.List(ctx, options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = nameSelector(name)
+			return client.Watch(ctx, options)
+		},
+	}
+}
+
+func deploymentListWatch(ctx context.Context, clientset kubernetes.Interface, namespace, name string) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = nameSelector(name)
+			return clientset.AppsV1().Deployments(namespace).List(ctx, options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = nameSelector(name)
+			return clientset.AppsV1().Deployments(namespace).Watch(ctx, options)
+		},
+	}
+}
+
+func podListWatch(ctx context.Context, clientset kubernetes.Interface, namespace string, options metav1.ListOptions) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(o metav1.ListOptions) (runtime.Object, error) {
+			o.FieldSelector = options.FieldSelector
+			o.LabelSelector = options.LabelSelector
+			return clientset.CoreV1().Pods(namespace).List(ctx, o)
+		},
+		WatchFunc: func(o metav1.ListOptions) (watch.Interface, error) {
+			o.FieldSelector = options.FieldSelector
+			o.LabelSelector = options.LabelSelector
+			return clientset.CoreV1().Pods(namespace).Watch(ctx, o)
+		},
+	}
+}
+
+// waitForDevWorkspace waits until condition is true on the DevWorkspace
+// status. It fails as soon as the DevWorkspace phase is Failed.
+// The status messages are reported to progress, that can be nil.
+func waitForDevWorkspace(
+	ctx context.Context,
+	client dynamic.ResourceInterface,
+	name string,
+	progress *progressReporter,
+	condition func(s session) bool,
+) (*unstructured.Unstructured, error) {
+	obj, err := waitFor(ctx, dynamicListWatch(ctx, client, name), &unstructured.Unstructured{}, nil,
+		func(event watch.Event) (bool, error) {
+			switch event.Type {
+			case watch.Deleted:
+				return false, fmt.Errorf("the DevWorkspace %s has been deleted", name)
+			case watch.Added, watch.Modified:
+				u, ok := event.Object.(*unstructured.Unstructured)
+				if !ok {
+					return false, nil
+				}
+				s := sessionFromUnstructured(*u)
+				progress.devWorkspace(s)
+				if s.phase == devWorkspaceFailed {
+					return false, fmt.Errorf("the DevWorkspace %s failed: %s", name, s.message)
+				}
+				return condition(s), nil
+			}
+			return false, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*unstructured.Unstructured), nil
+}
+
+// waitForDeploymentAvailable waits for the Deployment condition Available to be true
+func waitForDeploymentAvailable(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace, name string,
+	progress *progressReporter,
+) (*appv1.Deployment, error) {
+	obj, err := waitFor(ctx, deploymentListWatch(ctx, clientset, namespace, name), &appv1.Deployment{}, nil,
+		func(event watch.Event) (bool, error) {
+			d, ok := event.Object.(*appv1.Deployment)
+			if !ok || event.Type == watch.Deleted {
+				return false, nil
+			}
+			progress.deployment(d)
+			for _, condition := range d.Status.Conditions {
+				if condition.Type == appv1.DeploymentAvailable {
+					return condition.Status == corev1.ConditionTrue, nil
+				}
+			}
+			return false, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*appv1.Deployment), nil
+}
+
+// waitForPodReady waits for a Pod matching labelSelector to be ready
+func waitForPodReady(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace, labelSelector string,
+	progress *progressReporter,
+) (*corev1.Pod, error) {
+	lw := podListWatch(ctx, clientset, namespace, metav1.ListOptions{LabelSelector: labelSelector})
+	obj, err := waitFor(ctx, lw, &corev1.Pod{}, nil,
+		func(event watch.Event) (bool, error) {
+			p, ok := event.Object.(*corev1.Pod)
+			if !ok || event.Type == watch.Deleted || p.DeletionTimestamp != nil {
+				return false, nil
+			}
+			progress.pod(p)
+			return isPodReady(p), nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*corev1.Pod), nil
+}
+
+// waitForEphemeralContainer waits for an ephemeral container to be running.
+// It fails if the container terminates.
+func waitForEphemeralContainer(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace, podName, container string,
+	progress *progressReporter,
+) (*corev1.Pod, error) {
+	lw := podListWatch(ctx, clientset, namespace, metav1.ListOptions{FieldSelector: nameSelector(podName)})
+	obj, err := waitFor(ctx, lw, &corev1.Pod{}, nil,
+		func(event watch.Event) (bool, error) {
+			if event.Type == watch.Deleted {
+				return false, fmt.Errorf("the pod %s has been deleted", podName)
+			}
+			p, ok := event.Object.(*corev1.Pod)
+			if !ok {
+				return false, nil
+			}
+			progress.pod(p, container)
+			status := ephemeralContainerStatus(p, container)
+			if status == nil {
+				return false, nil
+			}
+			if t := status.State.Terminated; t != nil {
+				return false, fmt.Errorf("the container %s terminated (%s): %s", container, t.Reason, t.Message)
+			}
+			return status.State.Running != nil, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*corev1.Pod), nil
+}
+
+// waitForDeletion waits for the object name to be deleted
+func waitForDeletion(ctx context.Context, client dynamic.ResourceInterface, name string) error {
+	_, err := waitFor(ctx, dynamicListWatch(ctx, client, name), &unstructured.Unstructured{},
+		func(store cache.Store) (bool, error) {
+			return len(store.List()) == 0, nil
+		},
+		func(event watch.Event) (bool, error) {
+			return event.Type == watch.Deleted, nil
+		})
+	return err
+}